@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCtrlOOpensEditorWithoutCd(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-old-experiment"), 0755)
+
+	stdout, _, _ := runCmd(t, "cd", "--and-keys", "CTRL-O", "--path", dir)
+	if !strings.Contains(stdout, "${EDITOR:-vi}") || !strings.Contains(stdout, "old-experiment") {
+		t.Error("Ctrl-O should open the selected try in $EDITOR")
+	}
+	if strings.Contains(stdout, "&& cd ") {
+		t.Error("Ctrl-O should not change directory")
+	}
+}
+
+func TestCtrlYPrintsPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-old-experiment")
+	os.MkdirAll(path, 0755)
+
+	stdout, _, _ := runCmd(t, "cd", "--and-keys", "CTRL-Y", "--path", dir)
+	if !strings.Contains(stdout, "printf '%s\\n' '"+path+"'") {
+		t.Errorf("Ctrl-Y should print the selected path, got %q", stdout)
+	}
+	if strings.Contains(stdout, "&& cd ") {
+		t.Error("Ctrl-Y should not change directory")
+	}
+}
+
+func TestConfiguredActionRunsInsideTry(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-old-experiment"), 0755)
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(cfg, []byte(`{"actions": [{"key": "ctrl-g", "name": "status", "command": "git status"}]}`), 0644)
+
+	stdout, _, _ := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "cd", "--and-keys", "CTRL-G", "--path", dir)
+	if !strings.Contains(stdout, `cd "$1" && git status`) || !strings.Contains(stdout, "old-experiment") {
+		t.Errorf("configured action should run its command in the try, got %q", stdout)
+	}
+}
+
+func TestConfiguredActionCannotOverrideBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-delete-me")
+	os.MkdirAll(path, 0755)
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(cfg, []byte(`{"actions": [{"key": "ctrl-d", "name": "nope", "command": "true"}]}`), 0644)
+
+	stdout, _, _ := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "cd", "--and-keys", "CTRL-D,ESC", "--and-confirm", "YES", "--path", dir)
+	if strings.Contains(stdout, "true") {
+		t.Error("built-in keys should win over configured actions")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Ctrl-D should still delete the selected try")
+	}
+}
+
+func TestConfiguredActionOnReservedKeyWarns(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-old-experiment"), 0755)
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(cfg, []byte(`{"actions": [{"key": "ctrl-d", "name": "nope", "command": "true"}]}`), 0644)
+
+	cmd := exec.Command("./try", "cd", "--and-keys", "ESC", "--path", dir)
+	cmd.Env = append(os.Environ(), "TRY_CONFIG="+cfg)
+	out, _ := cmd.CombinedOutput()
+	if !strings.Contains(string(out), `Warning: ignoring action "nope": ctrl-d is taken by the selector`) {
+		t.Errorf("should name the action and its key, got %q", out)
+	}
+}
+
+func TestConfiguredActionWithUnsupportedKeyKeepsRestOfConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-old-experiment"), 0755)
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(cfg, []byte(`{"actions": [{"key": "alt-g", "name": "lazygit", "command": "lazygit"}], "aliases": {"work": "git@git.internal.example:"}}`), 0644)
+
+	cmd := exec.Command("./try", "cd", "--and-keys", "ESC", "--path", dir)
+	cmd.Env = append(os.Environ(), "TRY_CONFIG="+cfg)
+	out, _ := cmd.CombinedOutput()
+	if !strings.Contains(string(out), `Warning: ignoring action "lazygit": unsupported key "alt-g"`) || strings.Contains(string(out), "ignoring config") {
+		t.Errorf("should only skip the action, got %q", out)
+	}
+
+	stdout, _, _ := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "clone", "work:team/repo", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "git clone 'git@git.internal.example:team/repo'") {
+		t.Errorf("aliases should still apply, got %q", stdout)
+	}
+}
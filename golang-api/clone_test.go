@@ -1,13 +1,16 @@
 package main
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
 func TestCloneGeneratesScript(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, _ := runCmd(t, "clone", "https://github.com/tobi/try.git", "my-fork", "--path", dir)
+	stdout, _, _ := runCmd(t, "clone", "https://github.com/tobi/try.git", "my-fork", "--emit-script", "--path", dir)
 	if !strings.Contains(stdout, "mkdir -p") || !strings.Contains(stdout, "my-fork") {
 		t.Error("should emit mkdir with my-fork")
 	}
@@ -21,7 +24,7 @@ func TestCloneGeneratesScript(t *testing.T) {
 
 func TestCdUrlShorthandWithName(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, _ := runCmd(t, "cd", "https://github.com/tobi/try.git", "my-fork", "--path", dir)
+	stdout, _, _ := runCmd(t, "cd", "https://github.com/tobi/try.git", "my-fork", "--emit-script", "--path", dir)
 	if !strings.Contains(stdout, "git clone 'https://github.com/tobi/try.git'") {
 		t.Error("should emit git clone command")
 	}
@@ -32,7 +35,7 @@ func TestCdUrlShorthandWithName(t *testing.T) {
 
 func TestCdCloneWrapperEmitsCloneScript(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, _ := runCmd(t, "cd", "clone", "https://github.com/tobi/try.git", "my-fork", "--path", dir)
+	stdout, _, _ := runCmd(t, "cd", "clone", "https://github.com/tobi/try.git", "my-fork", "--emit-script", "--path", dir)
 	if !strings.Contains(stdout, "mkdir -p") || !strings.Contains(stdout, "my-fork") {
 		t.Error("should emit mkdir with my-fork")
 	}
@@ -46,7 +49,7 @@ func TestCdCloneWrapperEmitsCloneScript(t *testing.T) {
 
 func TestCloneEchoMessagePresent(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, _ := runCmd(t, "clone", "https://github.com/tobi/try.git", "my-fork", "--path", dir)
+	stdout, _, _ := runCmd(t, "clone", "https://github.com/tobi/try.git", "my-fork", "--emit-script", "--path", dir)
 	lower := strings.ToLower(stdout)
 	if !strings.Contains(lower, "git clone") || !strings.Contains(lower, "create this trial") {
 		t.Error("should contain echo message about git clone creating trial")
@@ -55,9 +58,357 @@ func TestCloneEchoMessagePresent(t *testing.T) {
 
 func TestCdUrlEchoMessagePresent(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, _ := runCmd(t, "cd", "https://github.com/tobi/try.git", "my-fork", "--path", dir)
+	stdout, _, _ := runCmd(t, "cd", "https://github.com/tobi/try.git", "my-fork", "--emit-script", "--path", dir)
 	lower := strings.ToLower(stdout)
 	if !strings.Contains(lower, "git clone") || !strings.Contains(lower, "create this trial") {
 		t.Error("should contain echo message about git clone creating trial")
 	}
 }
+
+func TestCloneExpandsShorthands(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"tobi/try":             "git clone 'https://github.com/tobi/try'",
+		"gh:tobi/try":          "git clone 'https://github.com/tobi/try'",
+		"gl:group/sub/project": "git clone 'https://gitlab.com/group/sub/project'",
+	}
+	for arg, want := range tests {
+		stdout, _, _ := runCmd(t, "clone", arg, "--emit-script", "--path", dir)
+		if !strings.Contains(stdout, want) {
+			t.Errorf("clone %s: expected %q in %q", arg, want, stdout)
+		}
+	}
+
+	stdout, _, _ := runCmd(t, "clone", "gl:group/sub/project", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "group-sub-project") {
+		t.Error("shorthand should generate the same directory name as the full URL")
+	}
+}
+
+func TestCdShorthandClones(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, _ := runCmd(t, "cd", "tobi/try", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "git clone 'https://github.com/tobi/try'") || !strings.Contains(stdout, "tobi-try") {
+		t.Errorf("bare user/repo should clone, got %q", stdout)
+	}
+}
+
+func TestCdShorthandPrefersLocalTries(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-tobi-test-retry"), 0755)
+	stdout, _, _ := runCmd(t, "cd", "tobi/try", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "git clone 'https://github.com/tobi/try'") {
+		t.Errorf("a try that merely fuzzy-matches should not stop the clone, got %q", stdout)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-tobi-try"), 0755)
+	stdout, _, _ = runCmd(t, "cd", "tobi/try", "--and-keys", "ENTER", "--path", dir)
+	if strings.Contains(stdout, "git clone") || !strings.Contains(stdout, "2025-08-14-tobi-try") {
+		t.Errorf("user/repo matching a try should select it, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmd(t, "cd", "gh:tobi/try", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "git clone 'https://github.com/tobi/try'") {
+		t.Errorf("gh:user/repo should always clone, got %q", stdout)
+	}
+}
+
+func TestConfiguredAliasAndDefaultHost(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(cfg, []byte(`{"aliases": {"work": "git@git.internal.example:"}, "default_host": "gl"}`), 0644)
+	env := map[string]string{"TRY_CONFIG": cfg}
+
+	stdout, _, _ := runCmdWithEnv(t, env, "clone", "work:team/service", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "git clone 'git@git.internal.example:team/service'") || !strings.Contains(stdout, "team-service") {
+		t.Errorf("custom alias should expand, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmdWithEnv(t, env, "cd", "org/project", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "git clone 'https://gitlab.com/org/project'") {
+		t.Errorf("default host should apply to bare shorthands, got %q", stdout)
+	}
+}
+
+func TestCloneOptionsAreEmittedAsFlags(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, err := runCmd(t, "clone", "https://github.com/big/monorepo.git",
+		"--depth", "1", "--branch", "release-1.0", "--recurse-submodules",
+		"--filter=blob:none", "--sparse", "pkg/server,cmd/tool", "--emit-script", "--path", dir)
+	if err != nil {
+		t.Fatalf("clone should succeed: %v", err)
+	}
+
+	want := "git clone --depth 1 --branch 'release-1.0' --recurse-submodules --filter='blob:none' --sparse 'https://github.com/big/monorepo.git'"
+	if !strings.Contains(stdout, want) {
+		t.Errorf("expected %q in %q", want, stdout)
+	}
+	if !strings.Contains(stdout, "sparse-checkout set 'pkg/server' 'cmd/tool'") {
+		t.Error("should restrict the checkout to the sparse paths")
+	}
+	if !strings.Contains(stdout, "big-monorepo") {
+		t.Error("options should not change the directory name")
+	}
+}
+
+func TestCloneRejectsInvalidDepth(t *testing.T) {
+	_, _, err := runCmd(t, "clone", "https://github.com/tobi/try.git", "--depth", "lots", "--path", t.TempDir())
+	if err == nil {
+		t.Error("non-numeric --depth should fail")
+	}
+}
+
+func TestCdUrlShorthandAcceptsCloneOptions(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, _ := runCmd(t, "cd", "--depth", "1", "https://github.com/tobi/try.git", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "git clone --depth 1 'https://github.com/tobi/try.git'") {
+		t.Errorf("url shorthand should honour clone options, got %q", stdout)
+	}
+}
+
+func TestSparseCloneFromLocalRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := newTestRepo(t, map[string]string{
+		"pkg/server/main.go": "package main\n",
+		"docs/readme.md":     "# docs\n",
+	})
+	tries := t.TempDir()
+
+	script, _, err := runCmd(t, "clone", "file://"+repo, "sparse", "--depth", "1", "--sparse", "pkg/server", "--path", tries)
+	if err != nil {
+		t.Fatalf("clone should succeed: %v", err)
+	}
+	if out, err := exec.Command("sh", "-c", script).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(tries, "*sparse"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one clone, got %v", matches)
+	}
+	if _, err := os.Stat(filepath.Join(matches[0], "pkg", "server", "main.go")); err != nil {
+		t.Error("sparse path should be checked out")
+	}
+	if _, err := os.Stat(filepath.Join(matches[0], "docs")); !os.IsNotExist(err) {
+		t.Error("paths outside the sparse set should not be checked out")
+	}
+}
+
+// newTestRepo creates a git repository with one commit containing files.
+func newTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repo := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	gitIn(t, repo, "init", "-q", "-b", "main")
+	gitIn(t, repo, "add", ".")
+	gitIn(t, repo, "commit", "-q", "-m", "initial")
+	return repo
+}
+
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=try", "GIT_AUTHOR_EMAIL=try@example.com",
+		"GIT_COMMITTER_NAME=try", "GIT_COMMITTER_EMAIL=try@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCloneTreeURLChecksOutRefAndCdsIntoSubdir(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, err := runCmd(t, "clone", "https://github.com/user/repo/tree/feature-x/pkg/server", "--emit-script", "--path", dir)
+	if err != nil {
+		t.Fatalf("clone should succeed: %v", err)
+	}
+	if !strings.Contains(stdout, "git clone --branch 'feature-x' 'https://github.com/user/repo' ") {
+		t.Errorf("should clone the repository at the linked ref, got %q", stdout)
+	}
+	if !strings.Contains(stdout, "user-repo/pkg/server'") {
+		t.Error("should cd into the linked subdirectory")
+	}
+	if strings.Contains(stdout, "sparse-checkout") {
+		t.Error("should not use a sparse checkout unless asked")
+	}
+}
+
+func TestCloneTreeURLWithBareSparse(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, _ := runCmd(t, "cd", "https://gitlab.com/group/sub/project/-/tree/dev/src/app", "--sparse", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "--branch 'dev' --sparse 'https://gitlab.com/group/sub/project'") {
+		t.Errorf("should clone the GitLab project sparsely at the ref, got %q", stdout)
+	}
+	if !strings.Contains(stdout, "sparse-checkout set 'src/app'") {
+		t.Error("bare --sparse should check out the linked subdirectory")
+	}
+}
+
+func TestCloneBlobURLAtCommit(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, _ := runCmd(t, "clone", "https://github.com/user/repo/blob/0123abcd/cmd/tool/main.go", "--emit-script", "--path", dir)
+	if strings.Contains(stdout, "--branch") {
+		t.Error("commits can't be passed to --branch")
+	}
+	if !strings.Contains(stdout, "checkout --quiet '0123abcd'") {
+		t.Error("should check out the linked commit after cloning")
+	}
+	if !strings.Contains(stdout, "user-repo/cmd/tool'") {
+		t.Error("should cd into the blob's directory")
+	}
+}
+
+func TestBareSparseNeedsSubdirectory(t *testing.T) {
+	_, _, err := runCmd(t, "clone", "https://github.com/user/repo", "--sparse", "--path", t.TempDir())
+	if err == nil {
+		t.Error("bare --sparse without a subdirectory link should fail")
+	}
+}
+
+func TestCloneFailureRollsBackNewTry(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	tries := t.TempDir()
+	script, _, _ := runCmd(t, "clone", "file://"+filepath.Join(t.TempDir(), "missing.git"), "gone", "--emit-script", "--path", tries)
+	out, err := exec.Command("sh", "-c", script).CombinedOutput()
+	if err == nil {
+		t.Fatal("script should fail when git clone fails")
+	}
+	if !strings.Contains(string(out), "does not appear to be a git repository") {
+		t.Errorf("git's error should be shown, got %q", out)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(tries, "*gone")); len(matches) != 0 {
+		t.Errorf("the half-created try should be removed, got %v", matches)
+	}
+}
+
+func TestCloneFailureKeepsExistingDirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	tries := t.TempDir()
+	existing := filepath.Join(tries, "kept")
+	os.MkdirAll(existing, 0755)
+	os.WriteFile(filepath.Join(existing, "notes.txt"), []byte("mine\n"), 0644)
+	cfg := writeConfig(t, `{"naming": {"format": "{name}", "collision": "reuse"}}`)
+
+	script, _, _ := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "clone", "file://"+filepath.Join(t.TempDir(), "missing.git"), "kept", "--path", tries)
+	exec.Command("sh", "-c", script).Run()
+	if _, err := os.Stat(filepath.Join(existing, "notes.txt")); err != nil {
+		t.Error("a directory that existed before must never be removed")
+	}
+}
+
+func TestCloneRunsGitAndEmitsOnlyCd(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := newTestRepo(t, map[string]string{"README.md": "hi\n"})
+	tries := t.TempDir()
+
+	stdout, stderr, err := runCmd(t, "clone", "file://"+repo, "native", "--path", tries)
+	if err != nil {
+		t.Fatalf("clone should succeed: %v\n%s", err, stderr)
+	}
+	matches, _ := filepath.Glob(filepath.Join(tries, "*native"))
+	if len(matches) != 1 {
+		t.Fatalf("expected the clone to exist already, got %v", matches)
+	}
+	if _, err := os.Stat(filepath.Join(matches[0], "README.md")); err != nil {
+		t.Error("repository should have been cloned by try itself")
+	}
+	if stdout != "touch '"+matches[0]+"' \\\n  && cd '"+matches[0]+"'" {
+		t.Errorf("only the touch and cd should be left for the shell, got %q", stdout)
+	}
+}
+
+func TestNativeCloneFailureRollsBack(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	tries := t.TempDir()
+	stdout, stderr, err := runCmd(t, "clone", "file://"+filepath.Join(t.TempDir(), "missing.git"), "gone", "--path", tries)
+	if err == nil {
+		t.Fatal("clone should exit non-zero when git fails")
+	}
+	if stdout != "" {
+		t.Errorf("nothing should be left for the shell to run, got %q", stdout)
+	}
+	if !strings.Contains(stderr, "does not appear to be a git repository") || !strings.Contains(stderr, "removed") {
+		t.Errorf("git's error and the rollback should be reported, got %q", stderr)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(tries, "*gone")); len(matches) != 0 {
+		t.Errorf("the half-created try should be removed, got %v", matches)
+	}
+}
+
+func TestCloneQuotesHostileURL(t *testing.T) {
+	dir := t.TempDir()
+	url := "https://github.com/a/b'$(touch pwned)'"
+	stdout, _, err := runCmd(t, "clone", url, "--emit-script", "--path", dir)
+	if err != nil {
+		t.Fatalf("clone should succeed: %v", err)
+	}
+	cmds, _, err := splitScript(stdout)
+	if err != nil {
+		t.Fatalf("script should parse: %v\n%s", err, stdout)
+	}
+	for _, cmd := range cmds {
+		if len(cmd) > 2 && cmd[0] == "git" && cmd[1] == "clone" && cmd[2] != url {
+			t.Errorf("git clone should get the URL as one word, got %q", cmd)
+		}
+	}
+}
+
+func TestCloneURLWithBracesDoesNotPanic(t *testing.T) {
+	stdout, _, err := runCmd(t, "clone", "https://github.com/a/{b}", "--emit-script", "--path", t.TempDir())
+	if err != nil || !strings.Contains(stdout, "from https://github.com/a/{b}.") {
+		t.Errorf("braces in a URL should be echoed as is, got %q (%v)", stdout, err)
+	}
+}
+
+func TestCloneRejectsOptionLikeBranch(t *testing.T) {
+	dir := t.TempDir()
+	_, _, err := runCmd(t, "clone", "https://github.com/tobi/try.git", "--branch=--upload-pack=touch", "--path", dir)
+	if err == nil {
+		t.Error("a branch starting with - should be refused")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("nothing should be reserved, found %d entries", len(entries))
+	}
+}
+
+func TestCloneFlagsNeedValues(t *testing.T) {
+	for _, args := range [][]string{
+		{"clone", "https://github.com/tobi/try.git", "--depth"},
+		{"cd", "https://github.com/tobi/try.git", "--depth"},
+		{"cd", "https://github.com/tobi/try.git", "--branch", "--depth", "1"},
+		{"cd", "tobi/try", "--filter="},
+	} {
+		_, stderr, err := runCmd(t, append(args, "--dry-run", "--path", t.TempDir())...)
+		if err == nil || !strings.Contains(stderr, "needs a value") {
+			t.Errorf("%v should fail for the missing value, got %v %q", args, err, stderr)
+		}
+	}
+}
+
+func TestCloneFlagsAreOnlyReadForRepositories(t *testing.T) {
+	_, stderr, err := runCmd(t, "cd", "depth", "--depth", "x", "--and-exit", "--path", t.TempDir())
+	if err != nil || strings.Contains(stderr, "Error") {
+		t.Errorf("a query should go to the selector, got %v %q", err, stderr)
+	}
+}
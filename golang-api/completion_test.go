@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompleteCommandsAndTries(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+	os.MkdirAll(filepath.Join(dir, "2025-08-15-website"), 0755)
+
+	stdout, _, _ := runCmd(t, "--path", dir, "__complete", "")
+	for _, want := range []string{"clone", "worktree", "init", "exec", "2025-08-14-redis-pool"} {
+		if !strings.Contains(stdout, want+"\n") {
+			t.Errorf("expected %q among first-word completions, got %q", want, stdout)
+		}
+	}
+
+	stdout, _, _ = runCmd(t, "--path", dir, "__complete", "exec", "red")
+	if stdout != "2025-08-14-redis-pool\n" {
+		t.Errorf("try names should match without their date prefix, got %q", stdout)
+	}
+}
+
+func TestCompleteFlags(t *testing.T) {
+	stdout, _, _ := runCmd(t, "__complete", "worktree", "--c")
+	if stdout != "--checkout\n" {
+		t.Errorf("expected worktree flags, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmd(t, "__complete", "clone", "--depth", "")
+	if stdout != "" {
+		t.Errorf("flag values have nothing to complete, got %q", stdout)
+	}
+}
+
+func TestCompleteWorktreeRepoPaths(t *testing.T) {
+	base := t.TempDir()
+	os.MkdirAll(filepath.Join(base, "repo", ".git"), 0755)
+	os.MkdirAll(filepath.Join(base, "plain"), 0755)
+
+	stdout, _, _ := runCmd(t, "__complete", "worktree", base+"/")
+	if !strings.Contains(stdout, base+"/repo\n") {
+		t.Errorf("git repositories should complete as-is, got %q", stdout)
+	}
+	if !strings.Contains(stdout, base+"/plain/\n") {
+		t.Errorf("other directories should complete with a slash, got %q", stdout)
+	}
+}
+
+func TestInitIncludesBashCompletion(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+	tryPath, _ := filepath.Abs("./try")
+
+	script := `eval "$(SHELL=/bin/bash "$1" init "$2")"
+COMP_WORDS=(try exec red); COMP_CWORD=2; _try
+printf '%s\n' "${COMPREPLY[@]}"
+complete -p try`
+	out, err := exec.Command("bash", "-c", script, "bash", tryPath, dir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "2025-08-14-redis-pool") {
+		t.Errorf("bash completion should offer try names, got %q", out)
+	}
+	if !strings.Contains(string(out), "complete -F _try try") {
+		t.Error("init should register the completion")
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	stdout, _, err := runCmd(t, "completion", "fish")
+	if err != nil || !strings.Contains(stdout, "complete -c try") {
+		t.Errorf("should print fish completions, got %v %q", err, stdout)
+	}
+
+	stdout, _, _ = runCmd(t, "completion", "zsh")
+	if !strings.Contains(stdout, "compdef _try try") {
+		t.Errorf("should print zsh completions, got %q", stdout)
+	}
+
+	if _, _, err := runCmd(t, "completion", "tcsh"); err == nil {
+		t.Error("unsupported shells should fail")
+	}
+}
+
+func TestCompleteKeepsGlobalFlags(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+	env := map[string]string{"TRY_PATH": t.TempDir(), "TRY_CONFIG": filepath.Join(t.TempDir(), "missing", "config.json")}
+
+	stdout, _, _ := runCmdWithEnv(t, env, "__complete", "--path", dir, "exec", "red")
+	if stdout != "2025-08-14-redis-pool\n" {
+		t.Errorf("a typed --path should pick the tries, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmdWithEnv(t, env, "__complete", "--dry-run", "--dialect", "")
+	if stdout != "" {
+		t.Errorf("--dialect values have nothing to complete, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmdWithEnv(t, env, "__complete", "--dry-run", "clone", "--d")
+	if stdout != "--dry-run\n--dry-run=json\n--dialect\n--depth\n" {
+		t.Errorf("flags should still be seen after --dry-run, got %q", stdout)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// trickyTries returns a tries directory whose path needs real quoting.
+func trickyTries(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), `it's $HOME \ "x"`)
+}
+
+func TestPosixDialectSurvivesTrickyPaths(t *testing.T) {
+	tries := trickyTries(t)
+	out, _, err := runCmd(t, "--path", tries, "cd", "new-thing", "--and-keys", "ENTER")
+	if err != nil {
+		t.Fatalf("create should succeed: %v", err)
+	}
+
+	pwd, err := exec.Command("bash", "-c", out+" && pwd").Output()
+	if err != nil {
+		t.Fatalf("bash should run %q: %v", out, err)
+	}
+	if got := strings.TrimSpace(string(pwd)); filepath.Dir(got) != tries {
+		t.Errorf("should cd into the new try under %q, got %q", tries, got)
+	}
+}
+
+func TestDialectQuoting(t *testing.T) {
+	tests := map[string]struct{ cd, quoted string }{
+		"posix":  {"cd '", `/it'"'"'s $HOME \ "x"/`},
+		"fish":   {"cd '", `/it\'s $HOME \\ "x"/`},
+		"pwsh":   {"Set-Location -LiteralPath '", `/it''s $HOME \ "x"/`},
+		"elvish": {"cd '", `/it''s $HOME \ "x"/`},
+	}
+	for dialect, want := range tests {
+		tries := trickyTries(t)
+		out, _, err := runCmd(t, "--path", tries, "--dialect", dialect, "cd", "new-thing", "--and-keys", "ENTER")
+		if err != nil {
+			t.Fatalf("%s: create should succeed: %v", dialect, err)
+		}
+		if !strings.Contains(out, want.cd) || !strings.Contains(out, want.quoted) {
+			t.Errorf("%s: should emit %s%s..., got %q", dialect, want.cd, want.quoted, out)
+		}
+	}
+}
+
+func TestDialectChainsSteps(t *testing.T) {
+	tests := map[string][]string{
+		"fish":   {" \\\n  && git clone ", " \\\n  || /usr/bin/env sh -c "},
+		"pwsh":   {"/usr/bin/env mkdir -p ", " && Write-Host ", " || /usr/bin/env sh -c "},
+		"elvish": {"\ntry {\n  git clone ", "\n} catch {\n  /usr/bin/env sh -c "},
+	}
+	for dialect, wants := range tests {
+		out, _, _ := runCmd(t, "--emit-script", "--dialect", dialect, "--path", t.TempDir(), "clone", "https://github.com/tobi/try.git")
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: should contain %q, got %q", dialect, want, out)
+			}
+		}
+	}
+}
+
+func TestUnknownDialectFails(t *testing.T) {
+	_, _, err := runCmd(t, "--dialect", "csh", "--path", t.TempDir(), "cd", "x", "--and-keys", "ENTER")
+	if err == nil {
+		t.Error("unknown dialects should fail")
+	}
+}
+
+func TestInitWrappersRequestTheirDialect(t *testing.T) {
+	tests := map[string]string{
+		"bash":   "--dialect posix",
+		"fish":   "--dialect fish",
+		"pwsh":   "'--dialect', 'pwsh'",
+		"nu":     "'--dialect' 'posix'",
+		"elvish": "--dialect elvish",
+		"xonsh":  "'--dialect', 'posix'",
+	}
+	for shell, want := range tests {
+		stdout, _, _ := runCmd(t, "init", "--shell", shell, t.TempDir())
+		if !strings.Contains(stdout, want) {
+			t.Errorf("%s wrapper should pass %q", shell, want)
+		}
+	}
+}
+
+func TestDialectEntersTryEnvironment(t *testing.T) {
+	tests := map[string][]string{
+		"posix":  {" \\\n  && export A='x y'", " \\\n  && . '"},
+		"fish":   {" \\\n  && set -gx A 'x y'", " \\\n  && source '"},
+		"pwsh":   {" && Set-Item -LiteralPath env:A -Value 'x y'", " && . '"},
+		"elvish": {"\nset-env A 'x y'", "\neval (slurp < '"},
+	}
+	onEnter := map[string]string{"posix": "on-enter", "fish": "on-enter.fish", "pwsh": "on-enter.ps1", "elvish": "on-enter.elv"}
+	for dialect, wants := range tests {
+		tries := t.TempDir()
+		enter := filepath.Join(tries, "2025-08-14-old-experiment", ".try")
+		os.MkdirAll(enter, 0755)
+		os.WriteFile(filepath.Join(enter, "env"), []byte(`A="x y"`+"\n"), 0644)
+		os.WriteFile(filepath.Join(enter, onEnter[dialect]), nil, 0644)
+
+		out, _, _ := runCmd(t, "--emit-script", "--dialect", dialect, "--path", tries, "cd", "old", "--and-keys", "ENTER")
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: should contain %q, got %q", dialect, want, out)
+			}
+		}
+		if !strings.HasSuffix(strings.TrimSpace(out), onEnter[dialect]+"'") && !strings.HasSuffix(strings.TrimSpace(out), onEnter[dialect]+"')") {
+			t.Errorf("%s: should source %s last, got %q", dialect, onEnter[dialect], out)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tobi/try/golang-api/internal/index"
+	"github.com/tobi/try/golang-api/internal/shell"
+)
+
+func TestCloneDryRunPrintsPlan(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, err := runCmd(t, "clone", "https://github.com/tobi/try.git", "--depth", "1", "--dry-run", "--path", dir)
+	if err != nil {
+		t.Fatalf("dry run should succeed: %v", err)
+	}
+	if !strings.Contains(stdout, "git clone --depth 1 https://github.com/tobi/try.git "+dir) {
+		t.Errorf("plan should show the git command, got %q", stdout)
+	}
+	if !strings.Contains(stdout, "tobi-try") {
+		t.Error("plan should show the resolved name")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Error("dry run should not create anything")
+	}
+}
+
+func TestWorktreeDryRunJSON(t *testing.T) {
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	dir := t.TempDir()
+
+	stdout, _, err := runCmd(t, "worktree", repo, "review", "--branch", "review", "--dry-run=json", "--path", dir)
+	if err != nil {
+		t.Fatalf("dry run should succeed: %v", err)
+	}
+
+	var plan shell.Plan
+	if err := json.Unmarshal([]byte(stdout), &plan); err != nil {
+		t.Fatalf("plan should be JSON: %v\n%s", err, stdout)
+	}
+	if !strings.HasSuffix(plan.Name, "-review") || plan.Target != filepath.Join(dir, plan.Name) {
+		t.Errorf("unexpected target %q / name %q", plan.Target, plan.Name)
+	}
+	var git []string
+	for _, step := range plan.Steps {
+		if step.Action == "git" {
+			git = step.Args
+		}
+	}
+	want := []string{"git", "-C", repo, "worktree", "add", "-b", "try/review", plan.Target}
+	if strings.Join(git, " ") != strings.Join(want, " ") {
+		t.Errorf("expected git step %v, got %v", want, git)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Error("dry run should not create anything")
+	}
+}
+
+func TestCreateNewDryRun(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, _ := runCmd(t, "cd", "new-thing", "--and-keys", "ENTER", "--dry-run", "--path", dir)
+	if !strings.Contains(stdout, "mkdir") || !strings.Contains(stdout, "new-thing") {
+		t.Errorf("plan should show the new try, got %q", stdout)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Error("dry run should not create anything")
+	}
+}
+
+func TestDeleteDryRunKeepsTry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-delete-me")
+	os.MkdirAll(path, 0755)
+
+	stdout, _, _ := runCmd(t, "cd", "--and-type", "delete-me", "--and-keys", "CTRL-D", "--and-confirm", "YES", "--dry-run", "--path", dir)
+	if !strings.Contains(stdout, "delete  "+path) {
+		t.Errorf("plan should show the deletion, got %q", stdout)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("dry run should not delete anything")
+	}
+}
+
+func TestDryRunRejectsUnknownFormat(t *testing.T) {
+	_, _, err := runCmd(t, "clone", "tobi/try", "--dry-run=yaml", "--path", t.TempDir())
+	if err == nil {
+		t.Error("unknown dry-run format should fail")
+	}
+}
+
+func TestDryRunLeavesDiskAlone(t *testing.T) {
+	tries := filepath.Join(t.TempDir(), "not-yet")
+	runCmd(t, "cd", "new-thing", "--and-keys", "ENTER", "--dry-run", "--path", tries)
+	if _, err := os.Stat(tries); err == nil {
+		t.Error("dry run should not create the tries directory")
+	}
+
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-existing"), 0755)
+	runCmd(t, "cd", "existing", "--and-keys", "ENTER", "--dry-run", "--path", dir)
+	if _, err := index.Load(dir); err == nil {
+		t.Error("dry run should not write the index cache")
+	}
+}
+
+func TestDryRunListsTryEnvironment(t *testing.T) {
+	tries := t.TempDir()
+	enter := filepath.Join(tries, "2025-08-14-old-experiment", ".try")
+	os.MkdirAll(enter, 0755)
+	os.WriteFile(filepath.Join(enter, "env"), []byte("A=1\n"), 0644)
+	os.WriteFile(filepath.Join(enter, "on-enter.fish"), nil, 0644)
+
+	stdout, _, err := runCmd(t, "cd", "old", "--and-keys", "ENTER", "--dialect", "fish", "--dry-run=json", "--path", tries)
+	if err != nil {
+		t.Fatalf("dry run should succeed: %v", err)
+	}
+	var plan shell.Plan
+	if err := json.Unmarshal([]byte(stdout), &plan); err != nil {
+		t.Fatalf("invalid json %q: %v", stdout, err)
+	}
+	var actions []string
+	for _, s := range plan.Steps {
+		actions = append(actions, s.Action+" "+s.Path)
+	}
+	want := []string{"touch " + plan.Target, "cd " + plan.Target, "env " + filepath.Join(enter, "env"), "source " + filepath.Join(enter, "on-enter.fish")}
+	if strings.Join(actions, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected steps %q, got %q", want, actions)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecRunsCommandInsideUniqueMatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-redis-pool")
+	os.MkdirAll(path, 0755)
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-thread-safe"), 0755)
+
+	stdout, _, err := runCmd(t, "exec", "redis", "--path", dir, "--", "pwd")
+	if err != nil {
+		t.Fatalf("exec should succeed: %v", err)
+	}
+	if strings.TrimSpace(stdout) != path {
+		t.Errorf("expected command to run in %s, got %q", path, stdout)
+	}
+}
+
+func TestExecLeavesArgumentsAfterSeparatorAlone(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+
+	stdout, _, err := runCmd(t, "exec", "redis", "--path", dir, "--", "echo", "--path", "elsewhere", "--and-exit")
+	if err != nil {
+		t.Fatalf("exec should succeed: %v", err)
+	}
+	if strings.TrimSpace(stdout) != "--path elsewhere --and-exit" {
+		t.Errorf("arguments after -- should reach the command untouched, got %q", stdout)
+	}
+}
+
+func TestExecPropagatesExitCode(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+
+	_, _, err := runCmd(t, "exec", "redis", "--path", dir, "--", "sh", "-c", "exit 3")
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit code 3, got %v", err)
+	}
+}
+
+func TestExecPrefersExactName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-pool")
+	os.MkdirAll(path, 0755)
+	os.MkdirAll(filepath.Join(dir, "2025-08-15-pool-party"), 0755)
+
+	stdout, _, _ := runCmd(t, "exec", "pool", "--path", dir, "--", "pwd")
+	if strings.TrimSpace(stdout) != path {
+		t.Errorf("exact name should win without the selector, got %q", stdout)
+	}
+}
+
+func TestExecAmbiguousMatchUsesSelector(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+	os.MkdirAll(filepath.Join(dir, "2025-08-15-redis-cache"), 0755)
+
+	stdout, stderr, err := runCmd(t, "exec", "redis", "--and-keys", "ENTER", "--path", dir, "--", "pwd")
+	if err != nil {
+		t.Fatalf("exec should succeed: %v", err)
+	}
+	if !strings.Contains(stripANSI(stderr), "Try Directory Selection") {
+		t.Error("ambiguous query should open the selector")
+	}
+	if !strings.Contains(stdout, filepath.Join(dir, "2025-08-1")) {
+		t.Errorf("should run in the selected try, got %q", stdout)
+	}
+}
+
+func TestExecCanCreateTry(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+	os.MkdirAll(filepath.Join(dir, "2025-08-15-redis-cache"), 0755)
+
+	stdout, _, err := runCmd(t, "exec", "redis", "--and-keys", "DOWN,DOWN,ENTER", "--path", dir, "--", "pwd")
+	if err != nil {
+		t.Fatalf("exec should run in the new try: %v", err)
+	}
+	if got := strings.TrimSpace(stdout); filepath.Dir(got) != dir || !strings.HasSuffix(got, "-redis") {
+		t.Errorf("should run in a new try named after the query, got %q", stdout)
+	}
+}
+
+func TestExecWithoutMatchFails(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool"), 0755)
+
+	_, stderr, err := runCmd(t, "exec", "nothing-like-it", "--path", dir, "--", "pwd")
+	if err == nil {
+		t.Error("exec without a match should fail")
+	}
+	if !strings.Contains(stderr, "no try matches") {
+		t.Error("should explain that nothing matched")
+	}
+}
+
+func TestExecRequiresCommand(t *testing.T) {
+	_, _, err := runCmd(t, "exec", "redis", "--path", t.TempDir())
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("exec without a command should exit 2, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tobi/try/golang-api/internal/naming"
+	"github.com/tobi/try/golang-api/internal/shell"
+)
+
+// splitScript reads the subset of sh the POSIX emitter writes: words of plain
+// characters and single-quoted strings (with '"'"' for a quote), chained by
+// && and || over backslash-newline continuations. Anything else, such as an
+// unquoted $ or ;, is an error, as it could start a command of its own.
+func splitScript(script string) (cmds [][]string, ops []string, err error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case c == '\'':
+			end := strings.IndexByte(script[i+1:], '\'')
+			if end < 0 {
+				return nil, nil, fmt.Errorf("unterminated quote at %d", i)
+			}
+			word.WriteString(script[i+1 : i+1+end])
+			inWord = true
+			i += end + 2
+		case strings.HasPrefix(script[i:], `"'"`):
+			word.WriteByte('\'')
+			inWord = true
+			i += 3
+		case strings.HasPrefix(script[i:], "\\\n"):
+			endWord()
+			i += 2
+		case c == ' ':
+			endWord()
+			i++
+		case strings.HasPrefix(script[i:], "&&"), strings.HasPrefix(script[i:], "||"):
+			endWord()
+			cmds = append(cmds, words)
+			words = nil
+			ops = append(ops, script[i:i+2])
+			i += 2
+		case strings.ContainsRune("\t\n;&|<>()$`\\\"*?[]#~", rune(c)):
+			return nil, nil, fmt.Errorf("unquoted %q at %d", c, i)
+		default:
+			word.WriteByte(c)
+			inWord = true
+			i++
+		}
+	}
+	endWord()
+	return append(cmds, words), ops, nil
+}
+
+func FuzzCloneScript(f *testing.F) {
+	f.Add("/tmp/tries/2025-08-27-tobi-try", "https://github.com/tobi/try.git", "Cloning {b}try{/b}.", "v1.0")
+	f.Add("/tmp/it's $HOME/x", "https://github.com/a/b'$(touch pwned)'", "", "")
+	f.Add(`/tmp/"quoted" \ back`, "git@host:o/r.git'; rm -rf ~; '", "&& echo hi", "main")
+	f.Add("/tmp/\\\n", "'\"'\"'", "'", "`id`")
+
+	f.Fuzz(func(t *testing.T, path, uri, msg, ref string) {
+		tasks := []shell.Task{
+			{Type: "target", Path: path},
+			{Type: "mkdir", Fresh: true},
+			{Type: "echo", Msg: msg},
+			{Type: "git-clone", URI: uri, Ref: ref},
+			{Type: "touch"},
+			{Type: "cd"},
+		}
+		if path == "" || shell.Validate(tasks) != nil {
+			t.Skip()
+		}
+
+		want := [][]string{{"mkdir", "-p", path}}
+		if msg != "" {
+			want = append(want, []string{"echo", msg})
+		}
+		want = append(want, []string{"git", "clone", uri, path})
+		wantOps := []string{"&&", "&&"}
+		if ref != "" {
+			want = append(want, []string{"git", "-C", path, "checkout", "--quiet", ref})
+			wantOps = append(wantOps, "&&")
+		}
+		if msg == "" {
+			wantOps = wantOps[1:]
+		}
+		want = append(want,
+			[]string{"/usr/bin/env", "sh", "-c", `rm -rf "$1"; echo "Removed $1 after git failed." >&2; exit 1`, "sh", path},
+			[]string{"touch", path},
+			[]string{"cd", path})
+		wantOps = append(wantOps, "||", "&&", "&&")
+
+		script, err := shell.Script(shell.POSIX, tasks)
+		if err != nil {
+			t.Fatalf("script should render: %v", err)
+		}
+		cmds, ops, err := splitScript(script)
+		if err != nil {
+			t.Fatalf("script doesn't parse: %v\n%s", err, script)
+		}
+		if !reflect.DeepEqual(cmds, want) || !reflect.DeepEqual(ops, wantOps) {
+			t.Fatalf("script parses as %q %q, want %q %q\n%s", cmds, ops, want, wantOps, script)
+		}
+	})
+}
+
+// FuzzNamingPolicyReserve reserves the same try twice and checks that every
+// name stays a single directory inside the tries path and that each collision
+// policy does what it says.
+func FuzzNamingPolicyReserve(f *testing.F) {
+	f.Add("test1", "", "", "version", false)
+	f.Add("it's $HOME", "", "", "suffix", false)
+	f.Add("", "~sircmpwn/..", "scdoc", "reuse", true)
+	f.Add(`"\'`, "", "", "error", false)
+	f.Add("..", "", "", "version", false)
+	f.Add("", "../..", "..", "suffix", false)
+
+	f.Fuzz(func(t *testing.T, name, owner, repo, collision string, lower bool) {
+		policy := naming.Policy{Collision: collision}
+		if lower {
+			policy.Slug = "lower"
+		}
+		if policy.Validate() != nil {
+			t.Skip()
+		}
+		root := t.TempDir()
+		dir := filepath.Join(root, "tries")
+		vars := naming.Vars{Name: name, Owner: owner, Repo: repo}
+
+		taken, err := policy.Resolve(dir, vars)
+		if err != nil {
+			if _, _, err := policy.Reserve(dir, vars); err == nil {
+				t.Fatalf("Reserve took a name Resolve refused for %+v", vars)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 0 {
+				t.Fatalf("a refused name left %v behind", entries)
+			}
+			return
+		}
+		if len(taken) > 200 {
+			t.Skip()
+		}
+
+		var reserved []string
+		for i := 0; i < 2; i++ {
+			got, fresh, err := policy.Reserve(dir, vars)
+			switch {
+			case i == 0 && (err != nil || got != taken || !fresh):
+				t.Fatalf("first Reserve = %q, %v, %v, want %q fresh", got, fresh, err, taken)
+			case i == 1 && collision == "error":
+				if err == nil {
+					t.Fatalf("second Reserve should fail, got %q", got)
+				}
+				continue
+			case i == 1 && collision == "reuse":
+				if err != nil || got != taken || fresh {
+					t.Fatalf("second Reserve = %q, %v, %v, want %q reused", got, fresh, err, taken)
+				}
+				continue
+			case i == 1 && (err != nil || got == taken || !fresh):
+				t.Fatalf("second Reserve = %q, %v, %v, want a fresh name besides %q", got, fresh, err, taken)
+			}
+
+			path := filepath.Join(dir, got)
+			if filepath.Dir(path) != dir || filepath.Base(path) != got {
+				t.Fatalf("%q is not a directory in %s", got, dir)
+			}
+			if info, err := os.Stat(path); err != nil || !info.IsDir() {
+				t.Fatalf("%q was not created: %v", got, err)
+			}
+			reserved = append(reserved, got)
+
+			tasks := []shell.Task{{Type: "target", Path: path}, {Type: "cd"}}
+			if shell.Validate(tasks) != nil {
+				continue
+			}
+			script, err := shell.Script(shell.POSIX, tasks)
+			if err != nil {
+				t.Fatalf("script should render: %v", err)
+			}
+			cmds, ops, err := splitScript(script)
+			if err != nil || len(ops) != 0 || !reflect.DeepEqual(cmds, [][]string{{"cd", path}}) {
+				t.Fatalf("script parses as %q (%v), want cd %q\n%s", cmds, err, path, script)
+			}
+		}
+
+		if entries, _ := os.ReadDir(root); len(entries) != 1 {
+			t.Fatalf("only the tries path should be created in %s, got %v", root, entries)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != len(reserved) {
+			t.Fatalf("want exactly %q in %s, got %v", reserved, dir, entries)
+		}
+	})
+}
+
+// FuzzBashWrapperQuoting sources the bash function for a hostile tries path
+// and checks that the binary gets the path back as a single argument.
+func FuzzBashWrapperQuoting(f *testing.F) {
+	f.Add("/tmp/it's $HOME")
+	f.Add(`/tmp/"quoted" \ back`)
+	f.Add("/tmp/$(touch pwned)/`id`")
+	f.Add("/tmp/x'; touch pwned; '")
+
+	f.Fuzz(func(t *testing.T, tries string) {
+		if strings.IndexFunc(tries, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 || !utf8.ValidString(tries) {
+			t.Skip()
+		}
+		dir := t.TempDir()
+		record := filepath.Join(dir, "args")
+		bin := filepath.Join(dir, "bin $x")
+		os.WriteFile(bin, []byte("#!/bin/sh\nprintf '%s\\0' \"$@\" > \"$TRY_RECORD\"\n"), 0755)
+
+		for _, path := range []string{tries, ""} {
+			wrapper, err := shell.Wrapper("bash", bin, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("bash", "-c", strings.ReplaceAll(wrapper, "/dev/tty", "/dev/null")+"\ntry query")
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "TRY_RECORD="+record)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("wrapper failed: %v\n%s", err, out)
+			}
+			data, _ := os.ReadFile(record)
+			args := strings.Split(strings.TrimSuffix(string(data), "\x00"), "\x00")
+			want := []string{"cd", "--dialect", "posix", "--protocol", "1", "query"}
+			if path != "" {
+				want = append([]string{"cd", "--path", path}, want[1:]...)
+			}
+			if !reflect.DeepEqual(args, want) {
+				t.Fatalf("binary got %q, want %q", args, want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+				t.Fatal("the tries path ran a command")
+			}
+		}
+	})
+}
@@ -3,6 +3,6 @@ module github.com/tobi/try/golang-api
 go 1.24.7
 
 require (
-	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/term v0.36.0 // indirect
+	golang.org/x/sys v0.37.0
+	golang.org/x/term v0.36.0
 )
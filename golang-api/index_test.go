@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tobi/try/golang-api/internal/index"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the selector's index out of the real ~/.cache while testing.
+	cacheDir, err := os.MkdirTemp("", "try-cache-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", cacheDir)
+	code := m.Run()
+	os.RemoveAll(cacheDir)
+	os.Exit(code)
+}
+
+func TestSelectorWritesIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-indexed"), 0755)
+
+	runCmd(t, "cd", "--and-exit", "--path", dir)
+
+	idx, err := index.Load(dir)
+	if err != nil {
+		t.Fatalf("index should have been written: %v", err)
+	}
+	if len(idx.Entries) != 1 || idx.Entries[0].Name != "2025-08-14-indexed" {
+		t.Errorf("unexpected index entries: %+v", idx.Entries)
+	}
+}
+
+func TestSelectorRendersFromFreshIndex(t *testing.T) {
+	dir := t.TempDir()
+	stat, _ := os.Stat(dir)
+
+	idx := &index.Index{
+		Root:      dir,
+		RootMtime: stat.ModTime(),
+		Entries:   []index.Entry{{Name: "2025-08-14-from-cache", Mtime: time.Now()}},
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("save index: %v", err)
+	}
+
+	stdout, stderr, _ := runCmd(t, "cd", "--and-exit", "--path", dir)
+	if !strings.Contains(stripANSI(stdout+stderr), "from-cache") {
+		t.Error("should render entries from a fresh index")
+	}
+}
+
+func TestSelectorRescansStaleIndex(t *testing.T) {
+	dir := t.TempDir()
+	idx := &index.Index{
+		Root:    dir,
+		Entries: []index.Entry{{Name: "2025-08-14-gone", Mtime: time.Now()}},
+	}
+	idx.Save()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-present"), 0755)
+
+	stdout, stderr, _ := runCmd(t, "cd", "--and-exit", "--path", dir)
+	clean := stripANSI(stdout + stderr)
+	if strings.Contains(clean, "gone") {
+		t.Error("should not render entries from a stale index")
+	}
+	if !strings.Contains(clean, "present") {
+		t.Error("should rescan when the root mtime changed")
+	}
+}
+
+func TestDeleteUpdatesIndex(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-delete-me"), 0755)
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-keep-me"), 0755)
+
+	runCmd(t, "cd", "--and-type", "delete-me", "--and-keys", "CTRL-D,ESC", "--and-confirm", "YES", "--path", dir)
+
+	idx, err := index.Load(dir)
+	if err != nil {
+		t.Fatalf("index should exist: %v", err)
+	}
+	for _, e := range idx.Entries {
+		if e.Name == "2025-08-14-delete-me" {
+			t.Error("deleted try should be removed from the index")
+		}
+	}
+}
+
+func TestIndexRemoveKeepsRootMtime(t *testing.T) {
+	dir := t.TempDir()
+	idx := &index.Index{
+		Root:      dir,
+		RootMtime: time.Now().Add(-time.Hour),
+		Entries:   []index.Entry{{Name: "2025-08-14-delete-me"}, {Name: "2025-08-14-keep-me"}},
+	}
+	// Created by something else after the index was written.
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-external"), 0755)
+
+	idx.Remove("2025-08-14-delete-me")
+	if len(idx.Entries) != 1 || idx.Entries[0].Name != "2025-08-14-keep-me" {
+		t.Errorf("should drop only the deleted try, got %+v", idx.Entries)
+	}
+	if idx.Fresh() {
+		t.Error("should stay stale so the next load finds the external try")
+	}
+}
+
+func TestLookupsSkipEnrichment(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-redis-pool", ".git"), 0755)
+	bin := t.TempDir()
+	marker := filepath.Join(bin, "ran")
+	os.WriteFile(filepath.Join(bin, "git"), []byte("#!/bin/sh\ntouch '"+marker+"'\n"), 0755)
+	env := map[string]string{"PATH": bin + string(os.PathListSeparator) + os.Getenv("PATH")}
+
+	stdout, _, err := runCmdWithEnv(t, env, "exec", "redis", "--path", dir, "--", "pwd")
+	if err != nil || !strings.Contains(stdout, "redis-pool") {
+		t.Fatalf("exec should succeed: %v %q", err, stdout)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("exec should not run git over the tries")
+	}
+	if _, err := index.Load(dir); err == nil {
+		t.Error("an unenriched scan should not be cached")
+	}
+}
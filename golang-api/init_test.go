@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -60,3 +61,186 @@ func TestInitEmitsFishFunctionWithPath(t *testing.T) {
 		t.Error("should contain switch statement")
 	}
 }
+
+func TestInitShellOverride(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"bash":   "try() {",
+		"zsh":    "try() {",
+		"fish":   "function try",
+		"pwsh":   "function tryout {",
+		"nu":     "def --env --wrapped tryout",
+		"elvish": "fn tryout {|@args|",
+		"xonsh":  "aliases['tryout'] = _tryout",
+	}
+	for shell, want := range tests {
+		stdout, _, err := runCmdWithEnv(t, map[string]string{"SHELL": "/bin/bash"}, "init", "--shell", shell, dir)
+		if err != nil {
+			t.Errorf("init --shell %s should succeed: %v", shell, err)
+		}
+		if !strings.Contains(stdout, want) {
+			t.Errorf("init --shell %s should contain %q, got %q", shell, want, stdout)
+		}
+		if !strings.Contains(stdout, dir) {
+			t.Errorf("init --shell %s should pass the tries path", shell)
+		}
+	}
+}
+
+func TestInitDetectsShellFromEnv(t *testing.T) {
+	stdout, _, _ := runCmdWithEnv(t, map[string]string{"SHELL": "/usr/local/bin/pwsh"}, "init", t.TempDir())
+	if !strings.Contains(stdout, "function tryout") {
+		t.Error("should emit the PowerShell wrapper when $SHELL is pwsh")
+	}
+}
+
+func TestInitRejectsUnknownShell(t *testing.T) {
+	_, _, err := runCmd(t, "init", "--shell", "tcsh")
+	if err == nil {
+		t.Error("unknown shells should fail")
+	}
+}
+
+func TestInitXonshWrapperIsValidPython(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+
+	stdout, _, _ := runCmd(t, "init", "--shell", "xonsh", t.TempDir())
+	cmd := exec.Command("python3", "-c", "import sys; compile(sys.stdin.read(), 'try.xsh', 'exec')")
+	cmd.Stdin = strings.NewReader(stdout)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("xonsh wrapper should parse: %v\n%s", err, out)
+	}
+}
+
+// The Nushell and Xonsh wrappers can't eval, so they ask for POSIX and run
+// exec directives with sh.
+func TestNonPosixWrappersRunExecDirectivesWithSh(t *testing.T) {
+	tries := t.TempDir()
+	path := filepath.Join(tries, "2025-08-14-old-experiment")
+	os.MkdirAll(path, 0755)
+
+	stdout, _, _ := runCmd(t, "init", "--shell", "xonsh", tries)
+	if !strings.Contains(stdout, `lines[0] != "#try 1"`) || !strings.Contains(stdout, `subprocess.call(['sh', '-c', line[5:]])`) {
+		t.Fatalf("wrapper should check the header and run exec with sh, got %q", stdout)
+	}
+
+	out, _, _ := runCmd(t, "--protocol", "1", "--dialect", "posix", "cd", "--and-keys", "CTRL-O", "--path", tries)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "exec ") {
+		t.Fatalf("Ctrl-O should give one exec directive, got %q", out)
+	}
+	cmd := exec.Command("sh", "-c", strings.TrimPrefix(lines[1], "exec "))
+	cmd.Env = append(os.Environ(), "EDITOR=echo")
+	edited, err := cmd.Output()
+	if err != nil || strings.TrimSpace(string(edited)) != path {
+		t.Errorf("sh should open the try in $EDITOR, got %q (%v)", edited, err)
+	}
+}
+
+func TestInitBindEmitsKeyBindings(t *testing.T) {
+	tests := []struct {
+		shell, key string
+		wants      []string
+	}{
+		{"bash", "ctrl-t", []string{`bindkey -M emacs '^T' __try_widget`, `'"\C-t": "\C-x\C-]1\C-m\C-x\C-]2"'`, "zle reset-prompt"}},
+		{"zsh", "alt-c", []string{`bindkey -M viins '^[c' __try_widget`, `'"\ec": `}},
+		{"fish", "ctrl-t", []string{`bind \ct __try_widget`, "commandline -f repaint"}},
+	}
+	for _, tt := range tests {
+		stdout, _, err := runCmd(t, "init", "--shell", tt.shell, "--bind", tt.key, t.TempDir())
+		if err != nil {
+			t.Errorf("init --bind %s should succeed for %s: %v", tt.key, tt.shell, err)
+		}
+		for _, want := range tt.wants {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("%s binding for %s should contain %q", tt.shell, tt.key, want)
+			}
+		}
+	}
+}
+
+func TestInitBindRejectsBadKeysAndShells(t *testing.T) {
+	if _, _, err := runCmd(t, "init", "--shell", "bash", "--bind", "ctrl-1", t.TempDir()); err == nil {
+		t.Error("keys other than ctrl- or alt-<letter> should fail")
+	}
+	if _, _, err := runCmd(t, "init", "--shell", "pwsh", "--bind", "ctrl-t", t.TempDir()); err == nil {
+		t.Error("--bind should fail for shells without a binding")
+	}
+}
+
+func TestInitBindIsValidBash(t *testing.T) {
+	stdout, _, _ := runCmd(t, "init", "--shell", "bash", "--bind", "ctrl-t", t.TempDir())
+	if out, err := exec.Command("bash", "-n", "-c", stdout).CombinedOutput(); err != nil {
+		t.Errorf("init output should parse: %v\n%s", err, out)
+	}
+}
+
+func runInit(t *testing.T, home string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("./try", append([]string{"init"}, args...)...)
+	cmd.Env = append(os.Environ(), "HOME="+home, "XDG_CONFIG_HOME=", "ZDOTDIR=")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("init %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestInitInstallIsIdempotent(t *testing.T) {
+	home := t.TempDir()
+	rc := filepath.Join(home, ".zshrc")
+	os.WriteFile(rc, []byte("export A=1\n"), 0600)
+
+	runInit(t, home, "--install", "--shell", "zsh", "/tmp/tries")
+	if out := runInit(t, home, "--install", "--shell", "zsh", "/tmp/tries"); !strings.Contains(out, "already installed") {
+		t.Errorf("a second install should change nothing, got %q", out)
+	}
+	runInit(t, home, "--install", "--shell", "zsh", "/tmp/other", "--bind", "ctrl-t")
+
+	data, _ := os.ReadFile(rc)
+	want := "export A=1\n\n# >>> try >>>\neval \"$("
+	if !strings.HasPrefix(string(data), want) || strings.Count(string(data), "# >>> try >>>") != 1 {
+		t.Fatalf("should keep one block after the existing content, got %q", data)
+	}
+	if !strings.Contains(string(data), "init --shell zsh /tmp/other --bind ctrl-t)\"\n# <<< try <<<\n") {
+		t.Errorf("should update the block in place, got %q", data)
+	}
+	if info, _ := os.Stat(rc); info.Mode().Perm() != 0600 {
+		t.Errorf("should keep the file mode, got %v", info.Mode())
+	}
+
+	runInit(t, home, "--uninstall", "--shell", "zsh")
+	if data, _ := os.ReadFile(rc); string(data) != "export A=1\n" {
+		t.Errorf("uninstall should restore the file, got %q", data)
+	}
+}
+
+func TestInitInstallPicksTheShellsRcFile(t *testing.T) {
+	home := t.TempDir()
+	runInit(t, home, "--install", "--shell", "fish")
+	runInit(t, home, "--install", "--shell", "bash")
+
+	fish, _ := os.ReadFile(filepath.Join(home, ".config", "fish", "config.fish"))
+	if !strings.Contains(string(fish), "init --shell fish | source") {
+		t.Errorf("fish should get a fish line in config.fish, got %q", fish)
+	}
+	bash, _ := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if !strings.Contains(string(bash), `eval "$(`) || strings.Contains(string(bash), "source") {
+		t.Errorf("bash should get an eval line in .bashrc, got %q", bash)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".zshrc")); err == nil {
+		t.Error("other shells' rc files should be left alone")
+	}
+}
+
+func TestInitInstallKeepsPath(t *testing.T) {
+	home := t.TempDir()
+	runInit(t, home, "--path", "~/tries", "--install", "--shell", "bash")
+
+	bash, _ := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if !strings.Contains(string(bash), "init --shell bash --path "+filepath.Join(home, "tries")+")\"") {
+		t.Errorf("an explicit --path should be kept in the rc file, got %q", bash)
+	}
+}
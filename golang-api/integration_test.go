@@ -10,7 +10,7 @@ import (
 
 func TestParseGitURIHTTPSGithubGeneratesCorrectPath(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, err := runCmd(t, "clone", "https://github.com/user/repo.git", "--path", dir)
+	stdout, _, err := runCmd(t, "clone", "https://github.com/user/repo.git", "--emit-script", "--path", dir)
 	if err != nil {
 		t.Logf("command error (may be expected): %v", err)
 	}
@@ -24,7 +24,7 @@ func TestParseGitURIHTTPSGithubGeneratesCorrectPath(t *testing.T) {
 
 func TestParseGitURISSHFormatGeneratesCorrectPath(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, err := runCmd(t, "clone", "git@github.com:user/repo.git", "--path", dir)
+	stdout, _, err := runCmd(t, "clone", "git@github.com:user/repo.git", "--emit-script", "--path", dir)
 	if err != nil {
 		t.Logf("command error (may be expected): %v", err)
 	}
@@ -35,7 +35,7 @@ func TestParseGitURISSHFormatGeneratesCorrectPath(t *testing.T) {
 
 func TestCloneWithCustomNameUsesCustomName(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, err := runCmd(t, "clone", "https://github.com/user/repo.git", "custom", "--path", dir)
+	stdout, _, err := runCmd(t, "clone", "https://github.com/user/repo.git", "custom", "--emit-script", "--path", dir)
 	if err != nil {
 		t.Logf("command error (may be expected): %v", err)
 	}
@@ -49,7 +49,7 @@ func TestCloneWithCustomNameUsesCustomName(t *testing.T) {
 
 func TestURLShorthandWorksLikeClone(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, err := runCmd(t, "cd", "https://github.com/user/repo.git", "--path", dir)
+	stdout, _, err := runCmd(t, "cd", "https://github.com/user/repo.git", "--emit-script", "--path", dir)
 	if err != nil {
 		t.Logf("command error (may be expected): %v", err)
 	}
@@ -60,7 +60,7 @@ func TestURLShorthandWorksLikeClone(t *testing.T) {
 
 func TestGitlabURLParsing(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, err := runCmd(t, "clone", "https://gitlab.com/org/project.git", "--path", dir)
+	stdout, _, err := runCmd(t, "clone", "https://gitlab.com/org/project.git", "--emit-script", "--path", dir)
 	if err != nil {
 		t.Logf("command error (may be expected): %v", err)
 	}
@@ -88,7 +88,7 @@ func TestWorktreeWithoutGitRepoOnlyCreatesDir(t *testing.T) {
 	repo := t.TempDir()
 	// No .git directory
 
-	stdout, _, _ := runCmdInDir(t, repo, "worktree", "dir", "test", "--path", tries)
+	stdout, _, _ := runCmdInDir(t, repo, "worktree", "dir", "test", "--emit-script", "--path", tries)
 
 	out := stdout
 	if !strings.Contains(out, "mkdir") {
@@ -104,7 +104,7 @@ func TestWorktreeWithGitRepoAddsWorktree(t *testing.T) {
 	repo := t.TempDir()
 	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
 
-	stdout, _, _ := runCmdInDir(t, repo, "worktree", "dir", "test", "--path", tries)
+	stdout, _, _ := runCmdInDir(t, repo, "worktree", "dir", "test", "--emit-script", "--path", tries)
 
 	out := stdout
 	if !strings.Contains(out, "worktree add") {
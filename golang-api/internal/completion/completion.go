@@ -0,0 +1,208 @@
+package completion
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Commands are the subcommands offered in first position, next to try names.
+var Commands = []string{"clone", "worktree", "init", "exec", "completion"}
+
+var (
+	globalFlags = []string{"--path", "--dry-run", "--dry-run=json", "--emit-script", "--dialect"}
+
+	commandFlags = map[string][]string{
+		"clone":    {"--depth", "--branch", "--recurse-submodules", "--filter", "--sparse"},
+		"worktree": {"--branch", "--checkout", "--ref", "--pr"},
+		"init":     {"--path", "--bind", "--install", "--uninstall"},
+	}
+
+	// valueFlags take the next word as their value, which has nothing to
+	// complete from.
+	valueFlags = map[string]bool{
+		"--path": true, "--dialect": true, "--bind": true, "--depth": true, "--branch": true, "--filter": true,
+		"--checkout": true, "--ref": true, "--pr": true, "--protocol": true,
+	}
+
+	shells = []string{"bash", "zsh", "fish"}
+
+	datePrefix = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-`)
+)
+
+// Complete returns the candidates for the last of words, the command line
+// after "try" up to the cursor. Candidates ending in "/" are directories to
+// descend into and shouldn't be followed by a space.
+func Complete(words []string, triesPath string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	prev := words[:len(words)-1]
+
+	if len(prev) > 0 && valueFlags[prev[len(prev)-1]] {
+		return nil
+	}
+
+	cmd := ""
+	var positional []string
+	for i, w := range prev {
+		if strings.HasPrefix(w, "-") || (i > 0 && valueFlags[prev[i-1]]) {
+			continue
+		}
+		if cmd == "" {
+			cmd = w
+			continue
+		}
+		positional = append(positional, w)
+	}
+	for _, w := range prev {
+		if w == "--" {
+			// Everything after -- belongs to the command run by exec.
+			return nil
+		}
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		return filter(append(append([]string{}, globalFlags...), commandFlags[cmd]...), cur)
+	}
+
+	switch cmd {
+	case "":
+		return append(filter(Commands, cur), Tries(triesPath, cur)...)
+	case "worktree":
+		if len(positional) == 0 {
+			return append(filter([]string{"dir"}, cur), Repos(cur)...)
+		}
+	case "exec", "cd":
+		return Tries(triesPath, cur)
+	case "completion":
+		if len(positional) == 0 {
+			return filter(shells, cur)
+		}
+	}
+	return nil
+}
+
+// Tries lists the tries whose name, with or without its date prefix, starts
+// with prefix.
+func Tries(triesPath, prefix string) []string {
+	entries, err := os.ReadDir(triesPath)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if strings.HasPrefix(name, prefix) || strings.HasPrefix(datePrefix.ReplaceAllString(name, ""), prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Repos lists git repositories matching the path prefix, plus other
+// directories (with a trailing "/") that may contain one.
+func Repos(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	if strings.HasPrefix(readDir, "~/") {
+		home, _ := os.UserHomeDir()
+		readDir = filepath.Join(home, readDir[2:])
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		full := filepath.Join(readDir, name)
+		if stat, err := os.Stat(full); err != nil || !stat.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(full, ".git")); err == nil {
+			paths = append(paths, dir+name)
+		} else {
+			paths = append(paths, dir+name+"/")
+		}
+	}
+	return paths
+}
+
+func filter(candidates []string, prefix string) []string {
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
+
+// Script returns the completion definitions for shell, asking bin for
+// candidates with the given tries path.
+func Script(shell, bin, triesPath string) (string, error) {
+	call := quote(bin)
+	if triesPath != "" {
+		call += " --path " + quote(triesPath)
+	}
+
+	switch shell {
+	case "bash", "zsh":
+		return fmt.Sprintf(`if [ -n "$ZSH_VERSION" ]; then
+  _try() {
+    local -a candidates
+    local c
+    candidates=("${(@f)$(/usr/bin/env %s __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    for c in "${candidates[@]}"; do
+      if [[ -z "$c" ]]; then
+        continue
+      elif [[ "$c" == */ ]]; then
+        compadd -U -S '' -- "$c"
+      else
+        compadd -U -- "$c"
+      fi
+    done
+  }
+  if (( $+functions[compdef] )); then
+    compdef _try try
+  fi
+elif [ -n "$BASH_VERSION" ]; then
+  _try() {
+    local IFS=$'\n'
+    COMPREPLY=($(/usr/bin/env %s __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    case "${COMPREPLY[*]}" in
+      */|*/$'\n'*) compopt -o nospace 2>/dev/null ;;
+    esac
+  }
+  complete -F _try try
+fi
+`, call, call), nil
+	case "fish":
+		return fmt.Sprintf(`complete -c try -e
+complete -c try -f -a '(/usr/bin/env %s __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`, strings.ReplaceAll(call, "'", `\'`)), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", shell)
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tobi/try/golang-api/internal/naming"
+)
+
+// Action is a user-defined selector keybinding that runs Command inside the
+// highlighted try, e.g. {"key": "ctrl-g", "name": "lazygit", "command": "lazygit"}.
+type Action struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+type Config struct {
+	Actions []Action `json:"actions"`
+
+	// Aliases map repository shorthand prefixes to clone URL prefixes, e.g.
+	// {"work": "git@git.internal.example:"} makes "work:team/repo" clonable.
+	Aliases map[string]string `json:"aliases"`
+	// DefaultHost is where bare "user/repo" shorthands point (default github.com).
+	DefaultHost string `json:"default_host"`
+
+	Naming naming.Policy `json:"naming"`
+
+	// WorktreePrefix is prepended to branches created with worktree --branch.
+	// Unset means "try/"; "" disables the prefix.
+	WorktreePrefix *string `json:"worktree_branch_prefix"`
+}
+
+func (c *Config) BranchPrefix() string {
+	if c.WorktreePrefix == nil {
+		return "try/"
+	}
+	return *c.WorktreePrefix
+}
+
+func Path() string {
+	if path := os.Getenv("TRY_CONFIG"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "try", "config.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "try", "config.json")
+}
+
+// Load reads the config file. A missing file is not an error and yields the
+// zero config. Action keys are left for the selector to check, so that one
+// bad binding only costs that action.
+func Load() (*Config, error) {
+	cfg := &Config{}
+	path := Path()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return &Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := cfg.Naming.Validate(); err != nil {
+		return &Config{}, fmt.Errorf("%s: naming: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// KeySequence maps a key name such as "ctrl-g" to the byte the terminal sends
+// for it in raw mode.
+func KeySequence(name string) (string, error) {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	if !strings.HasPrefix(lower, "ctrl-") || len(lower) != len("ctrl-")+1 {
+		return "", fmt.Errorf("unsupported key %q (expected ctrl-<letter>)", name)
+	}
+	ch := lower[len(lower)-1]
+	if ch < 'a' || ch > 'z' {
+		return "", fmt.Errorf("unsupported key %q (expected ctrl-<letter>)", name)
+	}
+	return string(rune(ch - 'a' + 1)), nil
+}
@@ -1,65 +1,234 @@
 package git
 
 import (
+	"net"
+	"net/url"
+	"path"
 	"regexp"
 	"strings"
-	"time"
 )
 
+// ParsedURI is a git remote broken into its parts. Owner is the full path
+// between host and repository, so nested GitLab groups come out as
+// "group/sub" and sourcehut users keep their "~".
+//
+// Ref and Subpath are set for web links into a repository such as
+// .../tree/<ref>/<path> or .../blob/<ref>/<file>. Subpath is always a
+// directory; blob links point at the file's directory. Refs containing
+// slashes can't be told apart from the path and are cut at the first one.
+//
+// User is the user info before the host, such as git or a token, kept so the
+// clone URL still authenticates.
 type ParsedURI struct {
-	User string
-	Repo string
-	Host string
+	Scheme  string
+	User    string
+	Host    string
+	Port    string
+	Owner   string
+	Repo    string
+	Ref     string
+	Subpath string
+}
+
+var (
+	schemeURI = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*)://(.*)$`)
+	scpURI    = regexp.MustCompile(`^(?:([^@/:]+)@)?([^@/:]+):(.+)$`)
+)
+
+// flatHosts have no nested groups: every repository is owner/repo, and
+// anything after that is a page such as issues/5.
+var flatHosts = map[string]bool{
+	"github.com":    true,
+	"bitbucket.org": true,
+	"codeberg.org":  true,
 }
 
 func ParseGitURI(uri string) *ParsedURI {
-	uri = strings.TrimSuffix(uri, ".git")
+	uri = strings.TrimSpace(uri)
+
+	var parsed ParsedURI
+	var repoPath string
+	scp := false
+
+	if m := schemeURI.FindStringSubmatch(uri); m != nil {
+		parsed.Scheme = strings.ToLower(m[1])
+		rest := m[2]
+
+		switch parsed.Scheme {
+		case "http", "https", "ssh", "git":
+		case "git+ssh", "ssh+git":
+			parsed.Scheme = "ssh"
+		case "file":
+			if !strings.HasPrefix(rest, "/") {
+				return nil
+			}
+			repoPath = rest
+		default:
+			return nil
+		}
+
+		if parsed.Scheme != "file" {
+			if u, err := url.Parse(parsed.Scheme + "://" + rest); err == nil {
+				parsed.Host, parsed.Port = u.Hostname(), u.Port()
+				if u.User != nil {
+					parsed.User = u.User.String()
+				}
+				repoPath = u.Path
+			} else if m := scpURI.FindStringSubmatch(rest); m != nil {
+				// ssh://git@host:owner/repo isn't valid, but it's common
+				// enough to read it the way the scp form would be read.
+				parsed.User, parsed.Host, repoPath = m[1], m[2], m[3]
+			} else {
+				return nil
+			}
+			if parsed.Host == "" {
+				return nil
+			}
+		}
+	} else if m := scpURI.FindStringSubmatch(uri); m != nil {
+		// Without a user, only accept dotted hosts so that queries like
+		// "redis:pool" are never mistaken for remotes.
+		if m[1] == "" && !strings.Contains(m[2], ".") {
+			return nil
+		}
+		parsed.Scheme = "ssh"
+		parsed.User = m[1]
+		parsed.Host = m[2]
+		repoPath = m[3]
+		scp = true
+	} else {
+		return nil
+	}
+
+	var segments []string
+	for _, seg := range strings.Split(repoPath, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+
+	// GitLab puts UI routes such as /-/tree/main after a lone "-".
+	for i, seg := range segments {
+		if seg == "-" {
+			if !parseTreeRoute(&parsed, segments[i+1:]) {
+				return nil
+			}
+			segments = segments[:i]
+			break
+		}
+	}
 
-	httpsGithub := regexp.MustCompile(`^https?://github\.com/([^/]+)/([^/]+)`)
-	if m := httpsGithub.FindStringSubmatch(uri); m != nil {
-		return &ParsedURI{User: m[1], Repo: m[2], Host: "github.com"}
+	// GitHub, Gitea and friends use owner/repo/tree/<ref>/<path> directly.
+	if (parsed.Scheme == "https" || parsed.Scheme == "http") && len(segments) >= 4 &&
+		(segments[2] == "tree" || segments[2] == "blob") {
+		if !parseTreeRoute(&parsed, segments[2:]) {
+			return nil
+		}
+		segments = segments[:2]
 	}
 
-	sshGithub := regexp.MustCompile(`^git@github\.com:([^/]+)/([^/]+)`)
-	if m := sshGithub.FindStringSubmatch(uri); m != nil {
-		return &ParsedURI{User: m[1], Repo: m[2], Host: "github.com"}
+	if flatHosts[strings.ToLower(parsed.Host)] && len(segments) > 2 {
+		segments = segments[:2]
 	}
 
-	httpsOther := regexp.MustCompile(`^https?://([^/]+)/([^/]+)/([^/]+)`)
-	if m := httpsOther.FindStringSubmatch(uri); m != nil {
-		return &ParsedURI{User: m[2], Repo: m[3], Host: m[1]}
+	// Azure DevOps: org/project/_git/repo over https, v3/org/project/repo over ssh.
+	for i, seg := range segments {
+		if seg == "_git" && i+1 < len(segments) {
+			segments = append(segments[:i:i], segments[i+1])
+			break
+		}
+	}
+	if strings.HasSuffix(parsed.Host, "dev.azure.com") && len(segments) > 0 && segments[0] == "v3" {
+		segments = segments[1:]
 	}
 
-	sshOther := regexp.MustCompile(`^git@([^:]+):([^/]+)/([^/]+)`)
-	if m := sshOther.FindStringSubmatch(uri); m != nil {
-		return &ParsedURI{User: m[2], Repo: m[3], Host: m[1]}
+	if len(segments) == 0 {
+		return nil
+	}
+	parsed.Repo = strings.TrimSuffix(segments[len(segments)-1], ".git")
+	if parsed.Repo == "" {
+		return nil
 	}
 
-	return nil
+	owners := segments[:len(segments)-1]
+	if parsed.Scheme == "file" {
+		// The whole filesystem path isn't an owner; the parent directory is
+		// the closest equivalent.
+		if len(owners) > 0 {
+			owners = owners[len(owners)-1:]
+		}
+	} else if len(owners) == 0 && !scp {
+		// Except for scp-style remotes like gitolite's git@host:repo, a
+		// URL without an owner is more likely a profile page.
+		return nil
+	}
+	parsed.Owner = strings.Join(owners, "/")
+
+	return &parsed
 }
 
-func GenerateCloneDirectoryName(gitURI, customName string) string {
-	if customName != "" {
-		return customName
+// parseTreeRoute reads the ref and subpath from a tree or blob route. It
+// fails for a subpath that leads out of the repository, which a pasted link
+// could use to send the shell anywhere.
+func parseTreeRoute(parsed *ParsedURI, route []string) bool {
+	if len(route) < 2 || (route[0] != "tree" && route[0] != "blob") {
+		return true
+	}
+	parsed.Ref = route[1]
+
+	subpath := path.Clean(strings.Join(route[2:], "/"))
+	if path.IsAbs(subpath) || subpath == ".." || strings.HasPrefix(subpath, "../") {
+		return false
 	}
+	if route[0] == "blob" {
+		subpath = path.Dir(subpath)
+	}
+	if subpath != "." {
+		parsed.Subpath = subpath
+	}
+	return true
+}
 
-	parsed := ParseGitURI(gitURI)
+// CloneURL is the URL to hand to git clone. Web links into a repository are
+// reduced to the repository itself; anything else is returned unchanged.
+func CloneURL(uri string) string {
+	parsed := ParseGitURI(uri)
 	if parsed == nil {
-		return ""
+		return uri
+	}
+	if parsed.Ref == "" && parsed.Subpath == "" && !isPage(uri, parsed) {
+		return uri
 	}
 
-	datePrefix := time.Now().Format("2006-01-02")
-	return datePrefix + "-" + parsed.User + "-" + parsed.Repo
+	host := parsed.Host
+	if parsed.Port != "" {
+		host = net.JoinHostPort(host, parsed.Port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if parsed.User != "" {
+		host = parsed.User + "@" + host
+	}
+	repo := parsed.Repo
+	if parsed.Owner != "" {
+		repo = parsed.Owner + "/" + repo
+	}
+	return parsed.Scheme + "://" + host + "/" + repo
+}
+
+// isPage reports whether uri links to a page past owner/repo on a flat host,
+// such as .../issues/5.
+func isPage(uri string, parsed *ParsedURI) bool {
+	if !flatHosts[strings.ToLower(parsed.Host)] || !strings.HasPrefix(parsed.Scheme, "http") {
+		return false
+	}
+	u, err := url.Parse(uri)
+	return err == nil && strings.Count(strings.Trim(u.Path, "/"), "/") > 1
 }
 
 func IsGitURI(arg string) bool {
 	if arg == "" {
 		return false
 	}
-	return strings.HasPrefix(arg, "https://") ||
-		strings.HasPrefix(arg, "http://") ||
-		strings.HasPrefix(arg, "git@") ||
-		strings.Contains(arg, "github.com") ||
-		strings.Contains(arg, "gitlab.com") ||
-		strings.HasSuffix(arg, ".git")
+	return ParseGitURI(ExpandShorthand(arg)) != nil
 }
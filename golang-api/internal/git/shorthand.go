@@ -0,0 +1,80 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+var builtinAliases = map[string]string{
+	"gh":   "https://github.com/",
+	"gl":   "https://gitlab.com/",
+	"bb":   "https://bitbucket.org/",
+	"cb":   "https://codeberg.org/",
+	"srht": "https://git.sr.ht/",
+}
+
+var aliases = copyAliases(builtinAliases)
+var defaultHost = "https://github.com/"
+
+var (
+	aliasShorthand = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_-]*):([^/].*)$`)
+	bareShorthand  = regexp.MustCompile(`^~?[a-zA-Z0-9_][a-zA-Z0-9_.-]*/[a-zA-Z0-9_.-]+$`)
+)
+
+func copyAliases(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// SetAliases adds or overrides shorthand prefixes, e.g. "work" mapping to
+// "git@git.internal.example:". Values may also be plain host names.
+func SetAliases(custom map[string]string) {
+	for name, prefix := range custom {
+		aliases[name] = normalizePrefix(prefix)
+	}
+}
+
+// SetDefaultHost sets where bare "user/repo" shorthands point. It accepts an
+// alias name, a host name or a full URL prefix.
+func SetDefaultHost(host string) {
+	if prefix, ok := aliases[host]; ok {
+		defaultHost = prefix
+		return
+	}
+	defaultHost = normalizePrefix(host)
+}
+
+func normalizePrefix(prefix string) string {
+	if strings.HasSuffix(prefix, ":") || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	if strings.Contains(prefix, "://") {
+		return prefix + "/"
+	}
+	return "https://" + prefix + "/"
+}
+
+// ExpandShorthand turns "gh:user/repo", "work:team/repo" or a bare
+// "user/repo" into a full clone URL. Anything else is returned unchanged.
+func ExpandShorthand(arg string) string {
+	if m := aliasShorthand.FindStringSubmatch(arg); m != nil {
+		if prefix, ok := aliases[m[1]]; ok {
+			return prefix + m[2]
+		}
+		return arg
+	}
+	if bareShorthand.MatchString(arg) {
+		return defaultHost + arg
+	}
+	return arg
+}
+
+// IsBareShorthand reports whether arg is a plain "user/repo", which could
+// just as well be a search for a local try. Alias prefixes, "~user/repo" and
+// URLs are always repositories.
+func IsBareShorthand(arg string) bool {
+	return bareShorthand.MatchString(arg) && !strings.HasPrefix(arg, "~")
+}
@@ -0,0 +1,54 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+var languageMarkers = []struct {
+	file string
+	lang string
+}{
+	{"go.mod", "go"},
+	{"Cargo.toml", "rust"},
+	{"package.json", "node"},
+	{"pyproject.toml", "python"},
+	{"requirements.txt", "python"},
+	{"Gemfile", "ruby"},
+	{"mix.exs", "elixir"},
+	{"build.zig", "zig"},
+	{"pom.xml", "java"},
+	{"build.gradle", "java"},
+	{"CMakeLists.txt", "c"},
+}
+
+func DetectLanguage(ctx context.Context, path string, meta map[string]string) {
+	for _, m := range languageMarkers {
+		if _, err := os.Stat(filepath.Join(path, m.file)); err == nil {
+			meta["lang"] = m.lang
+			return
+		}
+	}
+}
+
+// GitStatus sets git to dirty or clean, and clears it for tries that aren't
+// repositories (any more).
+func GitStatus(ctx context.Context, path string, meta map[string]string) {
+	delete(meta, "git")
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		return
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "-C", path, "status", "--porcelain").Output()
+	if err != nil {
+		return
+	}
+	if len(bytes.TrimSpace(out)) > 0 {
+		meta["git"] = "dirty"
+	} else {
+		meta["git"] = "clean"
+	}
+}
@@ -0,0 +1,102 @@
+package index
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type Entry struct {
+	Name  string            `json:"name"`
+	Mtime time.Time         `json:"mtime"`
+	Size  int64             `json:"size"`
+	Meta  map[string]string `json:"meta,omitempty"`
+}
+
+// Index is the on-disk snapshot of a tries root. It stays valid for as long
+// as the root's own mtime is unchanged, i.e. no entry was added or removed.
+type Index struct {
+	Root      string    `json:"root"`
+	RootMtime time.Time `json:"root_mtime"`
+	Entries   []Entry   `json:"entries"`
+}
+
+func CacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "try")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cache", "try")
+}
+
+func Path(root string) string {
+	sum := sha1.Sum([]byte(root))
+	return filepath.Join(CacheDir(), "index-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+func Load(root string) (*Index, error) {
+	data, err := os.ReadFile(Path(root))
+	if err != nil {
+		return nil, err
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	if idx.Root != root {
+		return nil, os.ErrNotExist
+	}
+	return &idx, nil
+}
+
+func (idx *Index) Fresh() bool {
+	stat, err := os.Stat(idx.Root)
+	if err != nil {
+		return false
+	}
+	return stat.ModTime().Equal(idx.RootMtime)
+}
+
+func (idx *Index) Save() error {
+	path := Path(idx.Root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".index-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Remove drops an entry after it was deleted from disk. The root mtime is
+// left as it was: the deletion changed it, so the next load rescans and picks
+// up anything else that changed since the index was written.
+func (idx *Index) Remove(name string) {
+	var entries []Entry
+	for _, e := range idx.Entries {
+		if e.Name != name {
+			entries = append(entries, e)
+		}
+	}
+	idx.Entries = entries
+}
@@ -0,0 +1,149 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+const defaultWorkers = 16
+
+// Enricher adds metadata about a single try to meta. Enrichers may be slow
+// (they can shell out to git) and should give up once ctx is done.
+type Enricher func(ctx context.Context, path string, meta map[string]string)
+
+// DefaultEnrichers only look at a try's top level, so their results hold for
+// as long as its mtime. Git's state changes with edits anywhere below it,
+// which the mtime doesn't reflect, so GitStatus runs on every scan.
+var (
+	DefaultEnrichers  = []Enricher{DetectLanguage}
+	VolatileEnrichers = []Enricher{GitStatus}
+)
+
+type Scanner struct {
+	Workers   int
+	Enrichers []Enricher
+	// Volatile enrichers run even when the rest of an entry's metadata is
+	// reused.
+	Volatile []Enricher
+
+	// Found, when set, receives every entry as soon as it has been stat'ed,
+	// before enrichment, so callers can show partial results.
+	Found chan<- Entry
+}
+
+func Scan(ctx context.Context, root string, prev *Index) (*Index, error) {
+	s := Scanner{Enrichers: DefaultEnrichers, Volatile: VolatileEnrichers}
+	return s.Scan(ctx, root, prev)
+}
+
+// Scan stats and enriches every directory in root using a pool of workers.
+// Metadata from prev is reused for entries whose mtime hasn't changed; only
+// the Volatile enrichers run again for those.
+func (s *Scanner) Scan(ctx context.Context, root string, prev *Index) (*Index, error) {
+	rootStat, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			names = append(names, de.Name())
+		}
+	}
+
+	known := map[string]Entry{}
+	if prev != nil {
+		for _, e := range prev.Entries {
+			known[e.Name] = e
+		}
+	}
+
+	workers := s.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	results := make([]*Entry, len(names))
+	jobs := make(chan int)
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for i := range jobs {
+				if entry, ok := s.scanOne(ctx, root, names[i], known); ok {
+					results[i] = &entry
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range names {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	idx := &Index{Root: root, RootMtime: rootStat.ModTime()}
+	for _, e := range results {
+		if e != nil {
+			idx.Entries = append(idx.Entries, *e)
+		}
+	}
+	return idx, nil
+}
+
+func (s *Scanner) scanOne(ctx context.Context, root, name string, known map[string]Entry) (Entry, bool) {
+	path := filepath.Join(root, name)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	entry := Entry{Name: name, Mtime: stat.ModTime(), Size: stat.Size()}
+	if s.Found != nil {
+		select {
+		case s.Found <- entry:
+		case <-ctx.Done():
+			return entry, false
+		}
+	}
+
+	enrichers := append(append([]Enricher{}, s.Enrichers...), s.Volatile...)
+	if old, ok := known[name]; ok && old.Mtime.Equal(entry.Mtime) {
+		// Copied, as volatile enrichers write to it and prev may still be
+		// in use.
+		enrichers = s.Volatile
+		entry.Meta = make(map[string]string, len(old.Meta))
+		for k, v := range old.Meta {
+			entry.Meta[k] = v
+		}
+	} else if len(enrichers) > 0 {
+		entry.Meta = map[string]string{}
+	}
+
+	for _, enrich := range enrichers {
+		if ctx.Err() != nil {
+			return entry, false
+		}
+		enrich(ctx, path, entry.Meta)
+	}
+	return entry, true
+}
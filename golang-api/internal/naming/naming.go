@@ -0,0 +1,210 @@
+package naming
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultFormat      = "{date}-{name}"
+	DefaultCloneFormat = "{date}-{owner}-{repo}"
+	DefaultDateLayout  = "2006-01-02"
+)
+
+// Policy decides the directory name of every new try, whether it comes from
+// the selector, a worktree or a clone.
+//
+// Format is used whenever a name is known; CloneFormat for clones without a
+// custom name. Both accept {date}, {name}, {owner} and {repo}. Slug is "keep"
+// (only spaces become dashes) or "lower" (lowercase, anything unusual becomes
+// a dash). Collision is "version" (bump a trailing number of the name, or
+// append -2 when the format doesn't end in {name} or there is no number),
+// "suffix" (always append -2, -3, ...), "reuse" or "error".
+type Policy struct {
+	Format      string `json:"format"`
+	CloneFormat string `json:"clone_format"`
+	DateLayout  string `json:"date_layout"`
+	Slug        string `json:"slug"`
+	Collision   string `json:"collision"`
+}
+
+type Vars struct {
+	Name  string
+	Owner string
+	Repo  string
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.Format == "" {
+		p.Format = DefaultFormat
+	}
+	if p.CloneFormat == "" {
+		p.CloneFormat = DefaultCloneFormat
+	}
+	if p.DateLayout == "" {
+		p.DateLayout = DefaultDateLayout
+	}
+	if p.Slug == "" {
+		p.Slug = "keep"
+	}
+	if p.Collision == "" {
+		p.Collision = "version"
+	}
+	return p
+}
+
+func (p Policy) Validate() error {
+	p = p.withDefaults()
+	switch p.Slug {
+	case "keep", "lower":
+	default:
+		return fmt.Errorf("unknown slug policy %q (expected keep or lower)", p.Slug)
+	}
+	switch p.Collision {
+	case "version", "suffix", "reuse", "error":
+	default:
+		return fmt.Errorf("unknown collision policy %q (expected version, suffix, reuse or error)", p.Collision)
+	}
+	return nil
+}
+
+// Render formats a name without looking at the filesystem.
+func (p Policy) Render(vars Vars, now time.Time) string {
+	p = p.withDefaults()
+	format := p.formatFor(vars)
+
+	values := []struct{ placeholder, value string }{
+		{"{date}", now.Format(p.DateLayout)},
+		{"{name}", p.slug(vars.Name)},
+		{"{owner}", p.slug(strings.ReplaceAll(strings.ReplaceAll(vars.Owner, "~", ""), "/", "-"))},
+		{"{repo}", p.slug(vars.Repo)},
+	}
+
+	// One pass, so a value is never read as a placeholder itself.
+	var pairs []string
+	for _, v := range values {
+		if v.value == "" {
+			// Drop the separator that came with an empty placeholder.
+			pairs = append(pairs, v.placeholder+"-", "", "-"+v.placeholder, "")
+		}
+		pairs = append(pairs, v.placeholder, v.value)
+	}
+	return strings.NewReplacer(pairs...).Replace(format)
+}
+
+func (p Policy) formatFor(vars Vars) string {
+	if vars.Name == "" {
+		return p.CloneFormat
+	}
+	return p.Format
+}
+
+func (p Policy) slug(s string) string {
+	if p.Slug == "lower" {
+		s = regexp.MustCompile(`[^a-z0-9._-]+`).ReplaceAllString(strings.ToLower(s), "-")
+		return strings.Trim(s, "-")
+	}
+	return strings.ReplaceAll(s, " ", "-")
+}
+
+// Resolve renders a name and applies the collision policy against basePath.
+func (p Policy) Resolve(basePath string, vars Vars) (string, error) {
+	p = p.withDefaults()
+
+	name := p.Render(vars, time.Now())
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) ||
+		strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("invalid directory name %q", name)
+	}
+
+	switch p.Collision {
+	case "reuse":
+		return name, nil
+	case "error":
+		if exists(filepath.Join(basePath, name)) {
+			return "", fmt.Errorf("%s already exists", filepath.Join(basePath, name))
+		}
+		return name, nil
+	case "suffix":
+		return Suffixed(basePath, name), nil
+	default:
+		// Only a number at the end of the name itself is a version; one at
+		// the end of e.g. {date} is not.
+		if !strings.HasSuffix(p.formatFor(vars), "{name}") {
+			return Suffixed(basePath, name), nil
+		}
+		return Versioned(basePath, name), nil
+	}
+}
+
+// Reserve resolves a name like Resolve and claims it with an exclusive mkdir,
+// so concurrent callers never end up sharing a directory. A name taken in the
+// meantime is resolved again, moving on to the next version. fresh reports
+// whether the directory was created here rather than reused.
+func (p Policy) Reserve(basePath string, vars Vars) (name string, fresh bool, err error) {
+	p = p.withDefaults()
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return "", false, err
+	}
+
+	for attempt := 0; attempt < 100; attempt++ {
+		name, err = p.Resolve(basePath, vars)
+		if err != nil {
+			return "", false, err
+		}
+
+		err = os.Mkdir(filepath.Join(basePath, name), 0755)
+		switch {
+		case err == nil:
+			return name, true, nil
+		case !os.IsExist(err):
+			return "", false, err
+		case p.Collision == "reuse":
+			return name, false, nil
+		case p.Collision == "error":
+			return "", false, fmt.Errorf("%s already exists", filepath.Join(basePath, name))
+		}
+	}
+	return "", false, fmt.Errorf("no free name for %q in %s", name, basePath)
+}
+
+// Suffixed returns name, or name-2, name-3, ... whichever is free first.
+func Suffixed(basePath, name string) string {
+	candidate := name
+	for i := 2; exists(filepath.Join(basePath, candidate)); i++ {
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return candidate
+}
+
+// Versioned returns name if it is free. Otherwise a trailing number is bumped
+// (test1 becomes test2) or, without one, -2, -3, ... is appended.
+func Versioned(basePath, name string) string {
+	if !exists(filepath.Join(basePath, name)) {
+		return name
+	}
+
+	if m := regexp.MustCompile(`^(.*?)(\d+)$`).FindStringSubmatch(name); m != nil {
+		stem := m[1]
+		num, _ := strconv.Atoi(m[2])
+		for n := num + 1; ; n++ {
+			candidate := fmt.Sprintf("%s%d", stem, n)
+			if !exists(filepath.Join(basePath, candidate)) {
+				return candidate
+			}
+		}
+	}
+
+	return Suffixed(basePath, name)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package selector
+
+import (
+	"os"
+	"time"
+)
+
+func waitForInput(f *os.File, timeout time.Duration) bool {
+	return true
+}
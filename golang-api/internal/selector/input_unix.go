@@ -0,0 +1,20 @@
+//go:build unix
+
+package selector
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func waitForInput(f *os.File, timeout time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(f.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+	if err == unix.EINTR {
+		return false
+	}
+	// On any other error fall back to a blocking read.
+	return err != nil || n > 0
+}
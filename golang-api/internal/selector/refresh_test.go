@@ -0,0 +1,83 @@
+package selector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tobi/try/golang-api/internal/index"
+)
+
+func newTry(dir, name string, age time.Duration) TryInfo {
+	t := time.Now().Add(-age)
+	return TryInfo{Name: name, Basename: name, Path: filepath.Join(dir, name), Ctime: t, Mtime: t}
+}
+
+func TestReplaceTriesKeepsCursorAndInput(t *testing.T) {
+	dir := t.TempDir()
+	ts := NewTrySelector("redis", dir, map[string]interface{}{"dry_run": true})
+	ts.AllTries = []TryInfo{
+		newTry(dir, "2025-08-14-redis-pool", time.Hour),
+		newTry(dir, "2025-08-13-redis-cache", 2*time.Hour),
+		newTry(dir, "2025-08-12-website", 3*time.Hour),
+	}
+	ts.CursorPos = 1 // redis-cache
+
+	ts.replaceTries(append([]TryInfo{newTry(dir, "2025-08-15-redis-fresh", 0)}, ts.AllTries...))
+	tries := ts.GetTries()
+	if ts.InputBuffer != "redis" {
+		t.Errorf("search input should be kept, got %q", ts.InputBuffer)
+	}
+	if len(tries) != 3 || tries[ts.CursorPos].Basename != "2025-08-13-redis-cache" {
+		t.Errorf("cursor should stay on redis-cache, got %d in %+v", ts.CursorPos, tries)
+	}
+
+	ts.CursorPos = len(ts.GetTries()) // "Create new"
+	ts.replaceTries(append(ts.AllTries, newTry(dir, "2025-08-16-redis-more", 0)))
+	if tries := ts.GetTries(); ts.CursorPos != len(tries) {
+		t.Errorf("cursor should stay on Create new, got %d of %d", ts.CursorPos, len(tries))
+	}
+}
+
+func TestFinishedScanRefreshesList(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2025-08-14-redis-pool", "2025-08-13-redis-cache"} {
+		os.MkdirAll(filepath.Join(dir, name), 0755)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "2025-08-14-redis-pool"), old, old)
+	os.Chtimes(filepath.Join(dir, "2025-08-13-redis-cache"), old.Add(-time.Hour), old.Add(-time.Hour))
+
+	ts := NewTrySelector("redis", dir, map[string]interface{}{"dry_run": true})
+	ts.live = true
+	ts.AllTries = []TryInfo{
+		newTry(dir, "2025-08-14-redis-pool", time.Hour),
+		newTry(dir, "2025-08-13-redis-cache", 2*time.Hour),
+	}
+	ts.CursorPos = 1
+	ts.InputBuffer = "redis-"
+
+	// A new try shows up and the background scan reports it.
+	os.MkdirAll(filepath.Join(dir, "2025-08-15-redis-fresh"), 0755)
+	idx, err := index.Scan(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	scanned := make(chan *index.Index, 1)
+	scanned <- idx
+	ts.scanned = scanned
+	ts.scanning = true
+
+	if key, ok := ts.nextKey(); ok || key != "" {
+		t.Fatalf("a finished scan is not a key press, got %q", key)
+	}
+	tries := ts.GetTries()
+	if len(tries) != 3 || ts.scanning {
+		t.Fatalf("the list should now hold the new try, got %+v", tries)
+	}
+	if ts.InputBuffer != "redis-" || tries[ts.CursorPos].Basename != "2025-08-13-redis-cache" {
+		t.Errorf("input and cursor should be kept, got %q on %s", ts.InputBuffer, tries[ts.CursorPos].Basename)
+	}
+}
@@ -1,8 +1,8 @@
 package selector
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
@@ -11,7 +11,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/tobi/try/golang-api/internal/config"
+	"github.com/tobi/try/golang-api/internal/index"
+	"github.com/tobi/try/golang-api/internal/naming"
 	"github.com/tobi/try/golang-api/internal/ui"
+	"github.com/tobi/try/golang-api/internal/watch"
 	"golang.org/x/term"
 )
 
@@ -23,6 +27,7 @@ type TryInfo struct {
 	Ctime    time.Time
 	Mtime    time.Time
 	Score    float64
+	Meta     map[string]string
 }
 
 type TrySelector struct {
@@ -38,8 +43,31 @@ type TrySelector struct {
 	TestNoCls      bool
 	TestKeys       []string
 	TestConfirm    string
+	DryRun         bool
+	Actions        map[string]config.Action
+	Naming         naming.Policy
+
+	index      *index.Index
+	live       bool
+	async      bool
+	scanning   bool
+	spinner    int
+	cancelScan context.CancelFunc
+	scanDone   chan struct{}
+	found      chan index.Entry
+	scanned    chan *index.Index
+	watcher    *watch.Watcher
 }
 
+// reservedKeys are bound by the selector itself and can't be taken by
+// user-configured actions.
+var reservedKeys = map[string]bool{
+	"\x03": true, "\x04": true, "\x08": true, "\n": true, "\x0B": true, "\r": true,
+	"\x0E": true, "\x0F": true, "\x10": true, "\x19": true,
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
 func NewTrySelector(searchTerm, basePath string, options map[string]interface{}) *TrySelector {
 	ts := &TrySelector{
 		SearchTerm:  strings.ReplaceAll(searchTerm, " ", "-"),
@@ -67,12 +95,44 @@ func NewTrySelector(searchTerm, basePath string, options map[string]interface{})
 	if confirm, ok := options["test_confirm"].(string); ok {
 		ts.TestConfirm = confirm
 	}
+	if dryRun, ok := options["dry_run"].(bool); ok {
+		ts.DryRun = dryRun
+	}
+	if policy, ok := options["naming"].(naming.Policy); ok {
+		ts.Naming = policy
+	}
+	if actions, ok := options["actions"].([]config.Action); ok {
+		ts.Actions = map[string]config.Action{}
+		for _, action := range actions {
+			key, err := config.KeySequence(action.Key)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring action %q: %v\n", action.Name, err)
+				continue
+			}
+			if reservedKeys[key] {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring action %q: %s is taken by the selector\n", action.Name, action.Key)
+				continue
+			}
+			ts.Actions[key] = action
+		}
+	}
 
-	os.MkdirAll(basePath, 0755)
+	// A dry run doesn't touch the disk; a missing root just lists nothing.
+	if !ts.DryRun {
+		os.MkdirAll(basePath, 0755)
+	}
 	return ts
 }
 
 func (ts *TrySelector) Run() map[string]interface{} {
+	// Tries looked up before, by name only, are loaded again with their
+	// metadata for display.
+	if !ts.live {
+		ts.live = true
+		ts.AllTries = nil
+	}
+	defer ts.stopScan()
+
 	if ts.TestRenderOnce {
 		tries := ts.GetTries()
 		ts.render(tries)
@@ -81,6 +141,7 @@ func (ts *TrySelector) Run() map[string]interface{} {
 
 	// Determine if we're in test mode
 	isTestMode := len(ts.TestKeys) > 0 || ts.TestNoCls
+	ts.async = !isTestMode
 
 	// Setup terminal raw mode for interactive input (only in non-test mode)
 	var oldState *term.State
@@ -99,6 +160,13 @@ func (ts *TrySelector) Run() map[string]interface{} {
 
 		// Clear screen initially
 		ui.Cls()
+
+		// Live updates are best effort; without a watcher the list is
+		// simply what it was at startup.
+		if w, err := watch.New(ts.BasePath); err == nil {
+			ts.watcher = w
+			defer w.Close()
+		}
 	}
 
 	for {
@@ -114,7 +182,10 @@ func (ts *TrySelector) Run() map[string]interface{} {
 
 		ts.render(tries)
 
-		key := ts.readKey()
+		key, ok := ts.nextKey()
+		if !ok {
+			continue
+		}
 
 		switch key {
 		case "\r":
@@ -145,9 +216,18 @@ func (ts *TrySelector) Run() map[string]interface{} {
 				ts.CursorPos = 0
 			}
 		case "\x04":
+			if ts.CursorPos < len(tries) && ts.DryRun {
+				// Report what would be deleted instead of asking to confirm.
+				if !isTestMode {
+					ui.Cls()
+				}
+				return map[string]interface{}{
+					"type": "delete",
+					"path": tries[ts.CursorPos].Path,
+				}
+			}
 			if ts.CursorPos < len(tries) {
 				ts.handleDelete(tries[ts.CursorPos])
-				ts.AllTries = nil
 			}
 		case "\x03", "\x1b":
 			// Clear screen before exit (only in non-test mode)
@@ -156,7 +236,36 @@ func (ts *TrySelector) Run() map[string]interface{} {
 			}
 			ts.Selected = nil
 			return nil
+		case "\x0F", "\x19":
+			if ts.CursorPos < len(tries) {
+				if !isTestMode {
+					ui.Cls()
+				}
+				resultType := "edit"
+				if key == "\x19" {
+					resultType = "print"
+				}
+				ts.Selected = map[string]interface{}{
+					"type": resultType,
+					"path": tries[ts.CursorPos].Path,
+				}
+				return ts.Selected
+			}
 		default:
+			if action, ok := ts.Actions[key]; ok {
+				if ts.CursorPos < len(tries) {
+					if !isTestMode {
+						ui.Cls()
+					}
+					ts.Selected = map[string]interface{}{
+						"type":    "action",
+						"path":    tries[ts.CursorPos].Path,
+						"command": action.Command,
+					}
+					return ts.Selected
+				}
+				continue
+			}
 			if len(key) == 1 && regexp.MustCompile(`[a-zA-Z0-9\-\_\. ]`).MatchString(key) {
 				ts.InputBuffer += key
 				ts.CursorPos = 0
@@ -170,37 +279,156 @@ func (ts *TrySelector) LoadAllTries() []TryInfo {
 		return ts.AllTries
 	}
 
-	var tries []TryInfo
-	entries, err := ioutil.ReadDir(ts.BasePath)
-	if err != nil {
-		return tries
+	idx, err := index.Load(ts.BasePath)
+	switch {
+	case !ts.live:
+		// Outside of Run only names matter: skip enrichment, which forks
+		// git for every try, and don't cache the unenriched result.
+		if err != nil || !idx.Fresh() {
+			scanner := index.Scanner{}
+			if idx, err = scanner.Scan(context.Background(), ts.BasePath, idx); err != nil {
+				return []TryInfo{}
+			}
+		}
+		ts.AllTries = triesFromIndex(idx)
+	case err == nil && idx.Fresh():
+		// A fresh index still has stale per-try mtimes, so render from it
+		// right away and let a background scan bring the times up to date.
+		ts.index = idx
+		ts.AllTries = triesFromIndex(idx)
+		ts.startScan(false)
+	case ts.async:
+		// Nothing usable to render yet: stream entries in as they're found.
+		ts.index = idx
+		ts.AllTries = []TryInfo{}
+		ts.startScan(true)
+	default:
+		idx, err := index.Scan(context.Background(), ts.BasePath, idx)
+		if err != nil {
+			return []TryInfo{}
+		}
+		ts.saveIndex(idx)
+		ts.index = idx
+		ts.AllTries = triesFromIndex(idx)
 	}
+	return ts.AllTries
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
+// saveIndex caches idx on disk, except in dry runs.
+func (ts *TrySelector) saveIndex(idx *index.Index) {
+	if !ts.DryRun {
+		idx.Save()
+	}
+}
 
-		path := filepath.Join(ts.BasePath, entry.Name())
-		stat, err := os.Stat(path)
+// startScan rescans the root in the background, replacing any scan still in
+// flight. With stream set, entries are delivered on ts.found as they're seen.
+func (ts *TrySelector) startScan(stream bool) {
+	ts.stopScan()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scanner := index.Scanner{Enrichers: index.DefaultEnrichers, Volatile: index.VolatileEnrichers}
+	scanned := make(chan *index.Index, 1)
+
+	done := make(chan struct{})
+	ts.cancelScan = cancel
+	ts.scanDone = done
+	ts.scanning = true
+	ts.scanned = scanned
+	ts.found = nil
+	if stream {
+		found := make(chan index.Entry, 64)
+		scanner.Found = found
+		ts.found = found
+	}
+
+	root, prev, save := ts.BasePath, ts.index, !ts.DryRun
+	go func() {
+		defer close(done)
+		idx, err := scanner.Scan(ctx, root, prev)
 		if err != nil {
-			continue
+			scanned <- nil
+			return
+		}
+		if save {
+			idx.Save()
 		}
+		scanned <- idx
+	}()
+}
 
-		tries = append(tries, TryInfo{
-			Name:     "📁 " + entry.Name(),
-			Basename: entry.Name(),
-			Path:     path,
-			IsNew:    false,
-			Ctime:    stat.ModTime(),
-			Mtime:    stat.ModTime(),
-		})
+// stopScan cancels the scan in flight and waits for it to let go of the
+// previous index, which the caller may then change.
+func (ts *TrySelector) stopScan() {
+	if ts.cancelScan != nil {
+		ts.cancelScan()
+		<-ts.scanDone
+		ts.cancelScan = nil
+	}
+	ts.scanning = false
+}
+
+func (ts *TrySelector) addFound(first index.Entry) {
+	all := append([]TryInfo{}, ts.AllTries...)
+	all = append(all, tryFromEntry(ts.BasePath, first))
+	for {
+		select {
+		case entry := <-ts.found:
+			all = append(all, tryFromEntry(ts.BasePath, entry))
+		default:
+			ts.replaceTries(all)
+			return
+		}
 	}
+}
 
-	ts.AllTries = tries
+// replaceTries swaps in a new list while keeping the cursor on the same try,
+// or on "Create new" if that is where it was.
+func (ts *TrySelector) replaceTries(all []TryInfo) {
+	tries := ts.GetTries()
+	current := ""
+	if ts.CursorPos < len(tries) {
+		current = tries[ts.CursorPos].Path
+	}
+
+	ts.AllTries = all
+	wasEmpty := len(tries) == 0
+	tries = ts.GetTries()
+
+	if current == "" {
+		if !wasEmpty {
+			ts.CursorPos = len(tries)
+		}
+		return
+	}
+	for i, try := range tries {
+		if try.Path == current {
+			ts.CursorPos = i
+			return
+		}
+	}
+}
+
+func triesFromIndex(idx *index.Index) []TryInfo {
+	tries := make([]TryInfo, 0, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		tries = append(tries, tryFromEntry(idx.Root, entry))
+	}
 	return tries
 }
 
+func tryFromEntry(root string, entry index.Entry) TryInfo {
+	return TryInfo{
+		Name:     "📁 " + entry.Name,
+		Basename: entry.Name,
+		Path:     filepath.Join(root, entry.Name),
+		IsNew:    false,
+		Ctime:    entry.Mtime,
+		Mtime:    entry.Mtime,
+		Meta:     entry.Meta,
+	}
+}
+
 func (ts *TrySelector) GetTries() []TryInfo {
 	allTries := ts.LoadAllTries()
 
@@ -366,7 +594,11 @@ func (ts *TrySelector) TruncateWithANSI(text string, maxLength int) string {
 }
 
 func (ts *TrySelector) render(tries []TryInfo) {
-	ui.Puts("{h1}📁 Try Directory Selection")
+	header := "{h1}📁 Try Directory Selection"
+	if ts.async && ts.scanning {
+		header += " {dim_text}" + spinnerFrames[ts.spinner%len(spinnerFrames)] + " scanning"
+	}
+	ui.Puts(header)
 	ui.Puts("{dim_text}────────────────────────────────────────")
 	ui.Puts(fmt.Sprintf("{highlight}Search: {reset}%s", ts.InputBuffer))
 	ui.Puts("{dim_text}────────────────────────────────────────")
@@ -423,6 +655,12 @@ func (ts *TrySelector) render(tries []TryInfo) {
 			timeText := ts.FormatRelativeTime(try.Mtime)
 			scoreText := fmt.Sprintf("%.1f", try.Score)
 			metaText := fmt.Sprintf("%s, %s", timeText, scoreText)
+			if try.Meta["git"] == "dirty" {
+				metaText = "dirty, " + metaText
+			}
+			if lang := try.Meta["lang"]; lang != "" {
+				metaText = lang + ", " + metaText
+			}
 
 			ui.Print(" ")
 			if isSelected {
@@ -456,7 +694,7 @@ func (ts *TrySelector) render(tries []TryInfo) {
 		ui.Puts("{highlight}" + ts.DeleteStatus + "{reset}")
 		ts.DeleteStatus = ""
 	} else {
-		ui.Puts("{dim_text}↑↓/Ctrl-P,N,J,K: Navigate  Enter: Select  Ctrl-D: Delete  ESC: Cancel{reset}")
+		ui.Puts("{dim_text}↑↓/Ctrl-P,N,J,K: Navigate  Enter: Select  Ctrl-O: Edit  Ctrl-Y: Print path  Ctrl-D: Delete  ESC: Cancel{reset}")
 	}
 
 	// Use TTY mode unless we're in test mode
@@ -464,6 +702,48 @@ func (ts *TrySelector) render(tries []TryInfo) {
 	ui.Flush(isTTY)
 }
 
+// nextKey waits for a keypress. It returns false instead when the list of
+// tries changed while waiting and has to be redrawn first.
+func (ts *TrySelector) nextKey() (string, bool) {
+	if len(ts.TestKeys) > 0 {
+		return ts.readKey(), true
+	}
+
+	var events chan struct{}
+	if ts.watcher != nil {
+		events = ts.watcher.Events
+	}
+
+	for {
+		select {
+		case entry := <-ts.found:
+			ts.addFound(entry)
+			return "", false
+		case idx := <-ts.scanned:
+			ts.scanning = false
+			ts.found = nil
+			// Skip scans that raced with a delete from this session.
+			if idx != nil && idx.Fresh() {
+				ts.index = idx
+				ts.replaceTries(triesFromIndex(idx))
+			}
+			return "", false
+		case <-events:
+			ts.startScan(false)
+			return "", false
+		default:
+		}
+
+		if waitForInput(os.Stdin, 100*time.Millisecond) {
+			return ts.readKey(), true
+		}
+		if ts.scanning {
+			ts.spinner++
+			return "", false
+		}
+	}
+}
+
 func (ts *TrySelector) readKey() string {
 	// For testing mode, use pre-defined keys
 	if len(ts.TestKeys) > 0 {
@@ -511,16 +791,28 @@ func (ts *TrySelector) readKey() string {
 }
 
 func (ts *TrySelector) handleCreateNew() map[string]interface{} {
-	datePrefix := time.Now().Format("2006-01-02")
-
-	var finalName string
 	if ts.InputBuffer != "" {
-		finalName = datePrefix + "-" + ts.InputBuffer
-		finalName = strings.ReplaceAll(finalName, " ", "-")
+		vars := naming.Vars{Name: ts.InputBuffer}
+		var finalName string
+		var fresh bool
+		var err error
+		if ts.DryRun {
+			finalName, err = ts.Naming.Resolve(ts.BasePath, vars)
+		} else {
+			finalName, fresh, err = ts.Naming.Reserve(ts.BasePath, vars)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return map[string]interface{}{
+				"type": "cancel",
+				"path": "",
+			}
+		}
 		fullPath := filepath.Join(ts.BasePath, finalName)
 		return map[string]interface{}{
-			"type": "mkdir",
-			"path": fullPath,
+			"type":  "mkdir",
+			"path":  fullPath,
+			"fresh": fresh,
 		}
 	}
 
@@ -534,7 +826,21 @@ func (ts *TrySelector) handleDelete(try TryInfo) {
 	if ts.TestConfirm == "YES" {
 		os.RemoveAll(try.Path)
 		ts.DeleteStatus = fmt.Sprintf("Deleted: %s", try.Basename)
-		ts.AllTries = nil
+
+		remaining := ts.AllTries[:0]
+		for _, t := range ts.AllTries {
+			if t.Path != try.Path {
+				remaining = append(remaining, t)
+			}
+		}
+		ts.AllTries = remaining
+
+		// An in-flight scan may still list the deleted try.
+		ts.stopScan()
+		if ts.index != nil {
+			ts.index.Remove(try.Basename)
+			ts.saveIndex(ts.index)
+		}
 	} else {
 		ts.DeleteStatus = "Delete cancelled"
 	}
@@ -0,0 +1,87 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Binding returns the key binding that opens the selector from any prompt,
+// cds to the choice through the try function and redraws the prompt, for
+// `try init --bind KEY`. key is ctrl-<letter> or alt-<letter>.
+//
+// bash can't redraw its prompt from a bind -x function, so the key runs a
+// macro instead: stash the line, run try, accept the now empty line to get a
+// fresh prompt, then put the line back.
+func Binding(shellName, key string) (string, error) {
+	ctrl, ch, err := parseBindKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	switch shellName {
+	case "bash", "zsh":
+		bashKey, zshKey := `\e`+ch, "^["+ch
+		if ctrl {
+			bashKey, zshKey = `\C-`+ch, "^"+strings.ToUpper(ch)
+		}
+		return fmt.Sprintf(`if [ -n "$ZSH_VERSION" ]; then
+  if [[ -o zle ]]; then
+    __try_widget() {
+      local precmd
+      try </dev/tty
+      for precmd in $precmd_functions; do
+        $precmd
+      done
+      zle reset-prompt
+    }
+    zle -N __try_widget
+    bindkey -M emacs '%s' __try_widget
+    bindkey -M viins '%s' __try_widget
+  fi
+elif [ -n "$BASH_VERSION" ] && [[ $- == *i* ]]; then
+  __try_widget() {
+    __try_line=$READLINE_LINE
+    __try_point=$READLINE_POINT
+    READLINE_LINE=
+    READLINE_POINT=0
+    try </dev/tty
+  }
+  __try_restore() {
+    READLINE_LINE=$__try_line
+    READLINE_POINT=$__try_point
+    unset __try_line __try_point
+  }
+  for __try_keymap in emacs-standard vi-insert; do
+    bind -m "$__try_keymap" -x '"\C-x\C-]1": __try_widget'
+    bind -m "$__try_keymap" -x '"\C-x\C-]2": __try_restore'
+    bind -m "$__try_keymap" '"%s": "\C-x\C-]1\C-m\C-x\C-]2"'
+  done
+  unset __try_keymap
+fi
+`, zshKey, zshKey, bashKey), nil
+	case "fish":
+		fishKey := `\e` + ch
+		if ctrl {
+			fishKey = `\c` + ch
+		}
+		return fmt.Sprintf(`if status is-interactive
+  function __try_widget
+    try </dev/tty
+    commandline -f repaint
+  end
+  bind %s __try_widget
+  bind -M insert %s __try_widget
+end
+`, fishKey, fishKey), nil
+	}
+	return "", fmt.Errorf("key bindings are only available for bash, zsh and fish, not %s", shellName)
+}
+
+func parseBindKey(key string) (ctrl bool, ch string, err error) {
+	lower := strings.ToLower(strings.TrimSpace(key))
+	mod, ch, ok := strings.Cut(lower, "-")
+	if !ok || (mod != "ctrl" && mod != "alt") || len(ch) != 1 || ch[0] < 'a' || ch[0] > 'z' {
+		return false, "", fmt.Errorf("unsupported key %q (expected ctrl-<letter> or alt-<letter>)", key)
+	}
+	return mod == "ctrl", ch, nil
+}
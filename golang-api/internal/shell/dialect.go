@@ -0,0 +1,114 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Emitter renders task scripts in the syntax of the shell that will evaluate
+// them. Commands are plain words, so a dialect only decides how words are
+// quoted, how a cd and a message look, and how steps are chained.
+type Emitter interface {
+	Quote(s string) string
+	Cd(path string) string
+	Echo(msg string) string
+	// Print writes path and a newline to stdout, verbatim.
+	Print(path string) string
+	Mkdir(path string) string
+	// Setenv exports a variable to the shell; Source runs a script in it.
+	Setenv(key, value string) string
+	Source(path string) string
+	// Join chains parts so that each runs only if the previous succeeded.
+	Join(parts []string) string
+	// OrElse runs fallback only when part fails; fallback must fail too.
+	OrElse(part, fallback string) string
+}
+
+var (
+	POSIX  Emitter = posix{}
+	Fish   Emitter = fish{}
+	Pwsh   Emitter = pwsh{}
+	Elvish Emitter = elvish{}
+)
+
+// EmitterFor maps a wrapper's --dialect to its Emitter. Shells that can't
+// evaluate a script of their own (Nushell, Xonsh) run POSIX output under sh.
+func EmitterFor(dialect string) (Emitter, error) {
+	switch dialect {
+	case "", "posix", "sh", "bash", "zsh", "nu", "xonsh":
+		return POSIX, nil
+	case "fish":
+		return Fish, nil
+	case "pwsh":
+		return Pwsh, nil
+	case "elvish":
+		return Elvish, nil
+	}
+	return nil, fmt.Errorf("unsupported dialect %q", dialect)
+}
+
+type posix struct{}
+
+func (posix) Quote(s string) string               { return shellQuote(s) }
+func (e posix) Cd(path string) string             { return "cd " + e.Quote(path) }
+func (e posix) Echo(msg string) string            { return "echo " + e.Quote(msg) }
+func (e posix) Print(path string) string          { return "printf '%s\\n' " + e.Quote(path) }
+func (e posix) Mkdir(path string) string          { return "mkdir -p " + e.Quote(path) }
+func (e posix) Setenv(key, value string) string   { return "export " + key + "=" + e.Quote(value) }
+func (e posix) Source(path string) string         { return ". " + e.Quote(path) }
+func (posix) Join(parts []string) string          { return JoinCommands(parts) }
+func (posix) OrElse(part, fallback string) string { return part + " \\\n  || " + fallback }
+
+// fish reads \\ and \' inside single quotes, so both are escaped rather than
+// closing the quote.
+type fish struct{}
+
+func (fish) Quote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+func (e fish) Cd(path string) string             { return "cd " + e.Quote(path) }
+func (e fish) Echo(msg string) string            { return "echo " + e.Quote(msg) }
+func (e fish) Print(path string) string          { return "printf '%s\\n' " + e.Quote(path) }
+func (e fish) Mkdir(path string) string          { return "mkdir -p " + e.Quote(path) }
+func (e fish) Setenv(key, value string) string   { return "set -gx " + key + " " + e.Quote(value) }
+func (e fish) Source(path string) string         { return "source " + e.Quote(path) }
+func (fish) Join(parts []string) string          { return JoinCommands(parts) }
+func (fish) OrElse(part, fallback string) string { return part + " \\\n  || " + fallback }
+
+// pwsh doubles quotes inside single-quoted strings, and treats the
+// typographic single quotes as quotes too. It has no backslash line
+// continuation, so chains stay on one line, and an assignment can't be part
+// of a chain, so variables are set with Set-Item. mkdir is a built-in function
+// there, hence the detour through env.
+type pwsh struct{}
+
+func (pwsh) Quote(s string) string {
+	return "'" + strings.NewReplacer("'", "''", "‘", "‘‘", "’", "’’",
+		"‚", "‚‚", "‛", "‛‛").Replace(s) + "'"
+}
+func (e pwsh) Cd(path string) string    { return "Set-Location -LiteralPath " + e.Quote(path) }
+func (e pwsh) Echo(msg string) string   { return "Write-Host " + e.Quote(msg) }
+func (e pwsh) Print(path string) string { return "Write-Output " + e.Quote(path) }
+func (e pwsh) Mkdir(path string) string { return "/usr/bin/env mkdir -p " + e.Quote(path) }
+func (e pwsh) Setenv(key, value string) string {
+	return "Set-Item -LiteralPath env:" + key + " -Value " + e.Quote(value)
+}
+func (e pwsh) Source(path string) string         { return ". " + e.Quote(path) }
+func (pwsh) Join(parts []string) string          { return strings.Join(parts, " && ") }
+func (pwsh) OrElse(part, fallback string) string { return part + " || " + fallback }
+
+// elvish stops at the first failing command, so steps are simply lines. Its
+// printf doesn't read backslash escapes, so paths are printed with echo.
+type elvish struct{}
+
+func (elvish) Quote(s string) string             { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+func (e elvish) Cd(path string) string           { return "cd " + e.Quote(path) }
+func (e elvish) Echo(msg string) string          { return "echo " + e.Quote(msg) }
+func (e elvish) Print(path string) string        { return "echo " + e.Quote(path) }
+func (e elvish) Mkdir(path string) string        { return "mkdir -p " + e.Quote(path) }
+func (e elvish) Setenv(key, value string) string { return "set-env " + key + " " + e.Quote(value) }
+func (e elvish) Source(path string) string       { return "eval (slurp < " + e.Quote(path) + ")" }
+func (elvish) Join(parts []string) string        { return strings.Join(parts, "\n") }
+func (elvish) OrElse(part, fallback string) string {
+	return "try {\n  " + part + "\n} catch {\n  " + fallback + "\n}"
+}
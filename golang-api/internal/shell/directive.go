@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProtocolVersion is the version of the directives below. The shell function
+// from `try init` passes the version it understands with --protocol, and
+// acts on output only when its header carries the same one; bump it whenever
+// a directive changes meaning. Shell functions skip directives they don't
+// know, so adding one needs no new version.
+const ProtocolVersion = 1
+
+// DirectiveHeader starts output meant for the shell function. Anything
+// without it, such as help text, is shown as is.
+const DirectiveHeader = "#try"
+
+// WriteDirectives writes what is left of tasks after Execute as one directive
+// per line:
+//
+//	cd <path>        change to path
+//	env <KEY=VALUE>  export a variable, as read from the try's .try/env
+//	source <path>    run the try's on-enter script in the shell itself
+//	echo <text>      print text
+//	exec <cmd>       run cmd, a single line in e's dialect
+//
+// Arguments run to the end of the line and are not quoted.
+func WriteDirectives(w io.Writer, e Emitter, tasks []Task) error {
+	targetPath, err := targetOf(tasks)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{fmt.Sprintf("%s %d", DirectiveHeader, ProtocolVersion)}
+	for _, t := range tasks {
+		switch t.Type {
+		case "target":
+		case "echo":
+			if t.Msg != "" {
+				lines = append(lines, "echo "+t.Msg)
+			}
+		case "cd":
+			if t.Path != "" {
+				lines = append(lines, "cd "+t.Path)
+			} else {
+				lines = append(lines, "cd "+targetPath)
+			}
+		case "env":
+			for _, kv := range t.Env {
+				lines = append(lines, "env "+kv)
+			}
+		case "source":
+			lines = append(lines, "source "+t.Path)
+		case "print":
+			lines = append(lines, "echo "+targetPath)
+		case "edit":
+			lines = append(lines, "exec "+editCommand(e, targetPath))
+		case "action":
+			if strings.ContainsAny(t.Cmd, "\r\n") {
+				return fmt.Errorf("action command %q must fit on one line", t.Cmd)
+			}
+			lines = append(lines, "exec "+actionCommand(e, t.Cmd, targetPath))
+		default:
+			return fmt.Errorf("%s can't be handed to the shell; run it first", t.Type)
+		}
+	}
+
+	_, err = fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
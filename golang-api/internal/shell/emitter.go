@@ -1,14 +1,11 @@
 package shell
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
-
-	"github.com/tobi/try/golang-api/internal/ui"
+	"unicode"
 )
 
 type Task struct {
@@ -16,103 +13,278 @@ type Task struct {
 	Path string
 	URI  string
 	Repo string
-	Msg  string
+	Msg  string // printed as is; it often quotes a URL or path
+	Cmd  string
+
+	// Env holds the KEY=VALUE pairs of an env task, which Execute adds
+	// after a cd from the try's .try/env; a source task's Path is its
+	// on-enter script.
+	Env []string
+	// Entered marks a cd whose env and source tasks already follow it;
+	// Script looks them up itself for any other cd.
+	Entered bool
+
+	// Fresh marks a mkdir whose directory was already reserved for this try,
+	// so it may be removed again when a later step fails.
+	Fresh bool
+
+	// git-clone options
+	Depth             int
+	Branch            string
+	Ref               string
+	RecurseSubmodules bool
+	Filter            string
+	SparseCheckout    bool
+	Sparse            []string
+
+	// git-worktree options. NewBranch is created at Ref (default HEAD),
+	// Checkout is an existing branch; with neither the worktree is detached
+	// at Ref.
+	NewBranch string
+	Checkout  string
+	// Remote and Fetch fetch a refspec first, e.g. a pull request head into
+	// the local ref given as Ref.
+	Remote string
+	Fetch  string
 }
 
-func EmitTasksScript(tasks []Task) {
-	var targetPath string
+// errNoTarget is returned for task lists without a target task.
+var errNoTarget = errors.New("tasks have no target path")
+
+// targetOf returns the path of the first target task.
+func targetOf(tasks []Task) (string, error) {
 	for _, t := range tasks {
-		if t.Type == "target" {
-			targetPath = t.Path
-			break
+		if t.Type == "target" && t.Path != "" {
+			return t.Path, nil
 		}
 	}
+	return "", errNoTarget
+}
 
-	if targetPath == "" {
-		panic("emit_tasks_script requires a target path")
+// Emit prints tasks as a script in e's dialect.
+func Emit(e Emitter, tasks []Task) error {
+	script, err := Script(e, tasks)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Print(script)
+	return err
+}
+
+// Script renders tasks in e's dialect. Every value is quoted; run Validate
+// first to keep out what quoting can't make safe.
+func Script(e Emitter, tasks []Task) (string, error) {
+	targetPath, err := targetOf(tasks)
+	if err != nil {
+		return "", err
 	}
 
 	parts := []string{}
-	quotedPath := shellQuote(targetPath)
+	quotedPath := e.Quote(targetPath)
+
+	// Only a try this script creates is removed again when git fails; an
+	// existing directory is never touched.
+	created := false
+	for _, t := range tasks {
+		if t.Type == "mkdir" {
+			_, err := os.Stat(targetPath)
+			created = t.Fresh || os.IsNotExist(err)
+		}
+	}
+	rollback := func() {
+		if created {
+			parts[len(parts)-1] = e.OrElse(parts[len(parts)-1], fmt.Sprintf("/usr/bin/env sh -c %s sh %s",
+				e.Quote(`rm -rf "$1"; echo "Removed $1 after git failed." >&2; exit 1`), quotedPath))
+		}
+	}
 
 	for _, t := range tasks {
 		switch t.Type {
 		case "echo":
 			if t.Msg != "" {
-				expanded := ui.ExpandTokens(t.Msg)
-				quotedMsg := shellQuote(expanded)
-				parts = append(parts, fmt.Sprintf("echo %s", quotedMsg))
+				parts = append(parts, e.Echo(t.Msg))
 			}
 		case "mkdir":
-			parts = append(parts, fmt.Sprintf("mkdir -p %s", quotedPath))
+			parts = append(parts, e.Mkdir(targetPath))
 		case "git-clone":
-			parts = append(parts, fmt.Sprintf("git clone '%s' %s", t.URI, quotedPath))
+			parts = append(parts, fmt.Sprintf("git clone %s%s %s", cloneFlags(e, t), e.Quote(t.URI), quotedPath))
+			if len(t.Sparse) > 0 {
+				parts = append(parts, fmt.Sprintf("git -C %s sparse-checkout set %s", quotedPath, quoteAll(e, t.Sparse)))
+			}
+			if t.Ref != "" {
+				parts = append(parts, fmt.Sprintf("git -C %s checkout --quiet %s", quotedPath, e.Quote(t.Ref)))
+			}
+			rollback()
 		case "git-worktree":
-			if t.Repo != "" {
-				quotedRepo := shellQuote(t.Repo)
-				parts = append(parts, fmt.Sprintf(
-					"/usr/bin/env sh -c 'if git -C %s rev-parse --is-inside-work-tree >/dev/null 2>&1; then repo=$(git -C %s rev-parse --show-toplevel); git -C \"$repo\" worktree add --detach %s >/dev/null 2>&1 || true; fi; exit 0'",
-					quotedRepo, quotedRepo, quotedPath))
-			} else {
-				parts = append(parts, fmt.Sprintf(
-					"/usr/bin/env sh -c 'if git rev-parse --is-inside-work-tree >/dev/null 2>&1; then repo=$(git rev-parse --show-toplevel); git -C \"$repo\" worktree add --detach %s >/dev/null 2>&1 || true; fi; exit 0'",
-					quotedPath))
+			repo := t.Repo
+			if repo == "" {
+				repo = "."
 			}
+			// The script runs under sh, so it is quoted for sh inside and for
+			// the dialect outside.
+			add := fmt.Sprintf(`git -C "$repo" worktree add %s`, worktreeArgs(t, targetPath))
+			if t.Fetch != "" {
+				add = fmt.Sprintf(`git -C "$repo" fetch --quiet %s %s && %s`, shellQuote(t.Remote), shellQuote(t.Fetch), add)
+			}
+			script := fmt.Sprintf(`repo=$(git -C %s rev-parse --show-toplevel) && %s`, shellQuote(repo), add)
+			parts = append(parts, fmt.Sprintf("/usr/bin/env sh -c %s", e.Quote(script)))
+			rollback()
 		case "touch":
 			parts = append(parts, fmt.Sprintf("touch %s", quotedPath))
 		case "cd":
-			parts = append(parts, fmt.Sprintf("cd %s", quotedPath))
+			if t.Path != "" {
+				parts = append(parts, e.Cd(t.Path))
+			} else {
+				parts = append(parts, e.Cd(targetPath))
+			}
+			if !t.Entered {
+				for _, et := range enterTasks(e, targetPath) {
+					parts = append(parts, enterCommands(e, et)...)
+				}
+			}
+		case "env", "source":
+			parts = append(parts, enterCommands(e, t)...)
+		case "edit":
+			parts = append(parts, editCommand(e, targetPath))
+		case "print":
+			parts = append(parts, e.Print(targetPath))
+		case "action":
+			parts = append(parts, actionCommand(e, t.Cmd, targetPath))
 		}
 	}
 
-	fmt.Print(JoinCommands(parts))
+	return e.Join(parts), nil
 }
 
-func JoinCommands(parts []string) string {
-	return strings.Join(parts, " \\\n  && ")
+// enterCommands writes an env or source task in e's dialect.
+func enterCommands(e Emitter, t Task) []string {
+	if t.Type == "source" {
+		return []string{e.Source(t.Path)}
+	}
+	var parts []string
+	for _, kv := range t.Env {
+		key, value, _ := strings.Cut(kv, "=")
+		parts = append(parts, e.Setenv(key, value))
+	}
+	return parts
 }
 
-func shellQuote(s string) string {
-	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+// editScript and actionScript are run by sh with the try as $1.
+const editScript = `exec ${EDITOR:-vi} "$1"`
+
+func actionScript(cmd string) string {
+	return `cd "$1" && ` + cmd
+}
+
+func editCommand(e Emitter, path string) string {
+	return fmt.Sprintf("/usr/bin/env sh -c %s sh %s", e.Quote(editScript), e.Quote(path))
+}
+
+func actionCommand(e Emitter, cmd, path string) string {
+	return fmt.Sprintf("/usr/bin/env sh -c %s sh %s", e.Quote(actionScript(cmd)), e.Quote(path))
 }
 
-func UniqueDirName(basePath, dirName string) string {
-	candidate := dirName
-	i := 2
-	for {
-		fullPath := filepath.Join(basePath, candidate)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			return candidate
+// Validate rejects values that no quoting makes safe: control characters,
+// which can split lines or rewrite the terminal, and git arguments starting
+// with a dash, which git would read as options.
+func Validate(tasks []Task) error {
+	for _, t := range tasks {
+		args := append([]string{t.URI, t.Branch, t.Ref, t.NewBranch, t.Checkout, t.Remote, t.Fetch}, t.Sparse...)
+		for _, v := range append(append([]string{t.Path, t.Repo, t.Msg, t.Filter}, args...), t.Env...) {
+			if strings.IndexFunc(v, unicode.IsControl) >= 0 {
+				return fmt.Errorf("refusing %q: it contains control characters", v)
+			}
+		}
+		for _, v := range args {
+			if strings.HasPrefix(v, "-") {
+				return fmt.Errorf("refusing %q: git would read it as an option", v)
+			}
 		}
-		candidate = fmt.Sprintf("%s-%d", dirName, i)
-		i++
 	}
+	return nil
 }
 
-func ResolveUniqueNameWithVersioning(basePath, datePrefix, base string) string {
-	initial := datePrefix + "-" + base
-	initialPath := filepath.Join(basePath, initial)
+func worktreeAddArgs(t Task, path string) []string {
+	var args []string
+	switch {
+	case t.NewBranch != "":
+		args = []string{"-b", t.NewBranch, path}
+	case t.Checkout != "":
+		return []string{path, t.Checkout}
+	default:
+		args = []string{"--detach", path}
+	}
+	if t.Ref != "" {
+		args = append(args, t.Ref)
+	}
+	return args
+}
 
-	if _, err := os.Stat(initialPath); os.IsNotExist(err) {
-		return base
+func worktreeArgs(t Task, path string) string {
+	args := worktreeAddArgs(t, path)
+	for i, arg := range args {
+		if arg != "-b" && arg != "--detach" {
+			args[i] = shellQuote(arg)
+		}
 	}
+	return strings.Join(args, " ")
+}
 
-	if matches := regexp.MustCompile(`^(.*?)(\d+)$`).FindStringSubmatch(base); matches != nil {
-		stem := matches[1]
-		num, _ := strconv.Atoi(matches[2])
-		candidateNum := num + 1
+func cloneArgs(t Task) []string {
+	var args []string
+	if t.Depth > 0 {
+		args = append(args, "--depth", fmt.Sprint(t.Depth))
+	}
+	if t.Branch != "" {
+		args = append(args, "--branch", t.Branch)
+	}
+	if t.RecurseSubmodules {
+		args = append(args, "--recurse-submodules")
+	}
+	if t.Filter != "" {
+		args = append(args, "--filter="+t.Filter)
+	}
+	if len(t.Sparse) > 0 {
+		args = append(args, "--sparse")
+	}
+	return args
+}
 
-		for {
-			candidateBase := fmt.Sprintf("%s%d", stem, candidateNum)
-			candidateFull := filepath.Join(basePath, datePrefix+"-"+candidateBase)
-			if _, err := os.Stat(candidateFull); os.IsNotExist(err) {
-				return candidateBase
-			}
-			candidateNum++
-		}
+func cloneFlags(e Emitter, t Task) string {
+	var flags []string
+	if t.Depth > 0 {
+		flags = append(flags, fmt.Sprintf("--depth %d", t.Depth))
+	}
+	if t.Branch != "" {
+		flags = append(flags, "--branch "+e.Quote(t.Branch))
+	}
+	if t.RecurseSubmodules {
+		flags = append(flags, "--recurse-submodules")
+	}
+	if t.Filter != "" {
+		flags = append(flags, "--filter="+e.Quote(t.Filter))
+	}
+	if len(t.Sparse) > 0 {
+		flags = append(flags, "--sparse")
+	}
+	if len(flags) == 0 {
+		return ""
+	}
+	return strings.Join(flags, " ") + " "
+}
+
+func quoteAll(e Emitter, values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = e.Quote(v)
 	}
+	return strings.Join(quoted, " ")
+}
+
+func JoinCommands(parts []string) string {
+	return strings.Join(parts, " \\\n  && ")
+}
 
-	fullName := datePrefix + "-" + base
-	unique := UniqueDirName(basePath, fullName)
-	return strings.TrimPrefix(unique, datePrefix+"-")
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
 }
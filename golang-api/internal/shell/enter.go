@@ -0,0 +1,134 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// EnterDir holds what entering a try should do: env, KEY=VALUE lines to
+// export, and an on-enter script in the shell's own language.
+const EnterDir = ".try"
+
+// onEnterNames is the on-enter script each dialect sources.
+var onEnterNames = map[Emitter]string{
+	POSIX:  "on-enter",
+	Fish:   "on-enter.fish",
+	Pwsh:   "on-enter.ps1",
+	Elvish: "on-enter.elv",
+}
+
+var envKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Entering returns the variables (as KEY=VALUE) and the on-enter script, if
+// any, for a shell speaking e that enters the try at tryPath. Problems are
+// reported on stderr rather than keeping the shell out of the try.
+//
+// Files that came with somebody else's code are ignored, and a cd shouldn't
+// run them: see usable.
+func Entering(e Emitter, tryPath string) (env []string, onEnter string) {
+	envFile, onEnter := enterFiles(e, tryPath)
+	if envFile != "" {
+		var err error
+		if env, err = readEnv(envFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring %s: %v\n", envFile, err)
+		}
+	}
+	return env, onEnter
+}
+
+// enterTasks returns the env and source tasks that follow a cd into the try
+// at tryPath for a shell speaking e.
+func enterTasks(e Emitter, tryPath string) []Task {
+	var tasks []Task
+	env, onEnter := Entering(e, tryPath)
+	if len(env) > 0 {
+		tasks = append(tasks, Task{Type: "env", Env: env})
+	}
+	if onEnter != "" {
+		tasks = append(tasks, Task{Type: "source", Path: onEnter})
+	}
+	return tasks
+}
+
+// enterFiles returns the env file and on-enter script of the try at tryPath
+// that may be used, leaving out missing and refused ones.
+func enterFiles(e Emitter, tryPath string) (envFile, onEnter string) {
+	if file := filepath.Join(tryPath, EnterDir, "env"); usable(tryPath, file) {
+		envFile = file
+	}
+	if name, ok := onEnterNames[e]; ok {
+		if file := filepath.Join(tryPath, EnterDir, name); usable(tryPath, file) {
+			onEnter = file
+		}
+	}
+	return envFile, onEnter
+}
+
+// usable reports whether file exists and was put there by the user rather
+// than by git: it must be in the same work tree as the try itself, which
+// rules out a submodule or nested repository at .try, and not be tracked.
+func usable(tryPath, file string) bool {
+	if _, err := os.Stat(file); err != nil {
+		return false
+	}
+	top := workTree(filepath.Dir(file))
+	if top != workTree(tryPath) {
+		fmt.Fprintf(os.Stderr, "Ignoring %s: it belongs to the repository at %s.\n", file, top)
+		return false
+	}
+	if top != "" && exec.Command("git", "-C", top, "ls-files", "--error-unmatch", "--", file).Run() == nil {
+		fmt.Fprintf(os.Stderr, "Ignoring %s: it comes with the repository.\n", file)
+		return false
+	}
+	return true
+}
+
+// workTree returns the top of the git work tree dir is in, or "" outside
+// of one.
+func workTree(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// readEnv parses KEY=VALUE lines, skipping blank lines and # comments. A
+// leading export is allowed, and values may be quoted; they are taken
+// literally, without expansion.
+func readEnv(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKey.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("line %d: control characters in %s", n, key)
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, scanner.Err()
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Execute performs the tasks that don't need the calling shell (creating the
+// try, git, bumping its mtime, messages) and returns what is left for Emit or
+// WriteDirectives: the target and any cd, edit, print or action. A cd is
+// followed by env and source tasks for the try's .try files, looked up for
+// a shell speaking e once the try is in place. A try created here is removed
+// again when a later step fails.
+func Execute(e Emitter, tasks []Task) ([]Task, error) {
+	targetPath, err := targetOf(tasks)
+	if err != nil {
+		return nil, err
+	}
+	rest := []Task{{Type: "target", Path: targetPath}}
+
+	created := false
+	for _, t := range tasks {
+		var err error
+		switch t.Type {
+		case "target":
+		case "echo":
+			if t.Msg != "" {
+				fmt.Fprintln(os.Stderr, t.Msg)
+			}
+		case "mkdir":
+			_, statErr := os.Stat(targetPath)
+			if err = os.MkdirAll(targetPath, 0755); err == nil {
+				created = created || t.Fresh || os.IsNotExist(statErr)
+			}
+		case "git-clone":
+			err = executeClone(t, targetPath)
+		case "git-worktree":
+			err = executeWorktree(t, targetPath)
+		case "touch":
+			now := time.Now()
+			err = os.Chtimes(targetPath, now, now)
+		case "cd":
+			t.Entered = true
+			rest = append(append(rest, t), enterTasks(e, targetPath)...)
+		default:
+			rest = append(rest, t)
+		}
+
+		if err != nil {
+			if created {
+				os.RemoveAll(targetPath)
+				return nil, fmt.Errorf("%w; removed %s", err, targetPath)
+			}
+			return nil, err
+		}
+	}
+	return rest, nil
+}
+
+func executeClone(t Task, path string) error {
+	args := append([]string{"clone"}, cloneArgs(t)...)
+	if err := runGit(append(args, t.URI, path)...); err != nil {
+		return err
+	}
+	if len(t.Sparse) > 0 {
+		if err := runGit(append([]string{"-C", path, "sparse-checkout", "set"}, t.Sparse...)...); err != nil {
+			return err
+		}
+	}
+	if t.Ref != "" {
+		return runGit("-C", path, "checkout", "--quiet", t.Ref)
+	}
+	return nil
+}
+
+func executeWorktree(t Task, path string) error {
+	repo := t.Repo
+	if repo == "" {
+		repo = "."
+	}
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return fmt.Errorf("%s is not inside a git repository", repo)
+	}
+	top := strings.TrimSpace(string(out))
+
+	if t.Fetch != "" {
+		if err := runGit("-C", top, "fetch", "--quiet", t.Remote, t.Fetch); err != nil {
+			return err
+		}
+	}
+	return runGit(append([]string{"-C", top, "worktree", "add"}, worktreeAddArgs(t, path)...)...)
+}
+
+// runGit shows git's progress and errors on stderr; stdout belongs to the
+// script handed back to the shell.
+func runGit(args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return nil
+}
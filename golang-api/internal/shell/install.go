@@ -0,0 +1,168 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The block `try init --install` manages in a startup file. Everything
+// between the markers belongs to try and is replaced on every install.
+const (
+	blockStart = "# >>> try >>>"
+	blockEnd   = "# <<< try <<<"
+)
+
+// RcFile returns the startup file of shellName that --install edits.
+func RcFile(shellName string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		config = filepath.Join(home, ".config")
+	}
+
+	switch shellName {
+	case "bash":
+		return filepath.Join(home, ".bashrc"), nil
+	case "zsh":
+		if dir := os.Getenv("ZDOTDIR"); dir != "" {
+			return filepath.Join(dir, ".zshrc"), nil
+		}
+		return filepath.Join(home, ".zshrc"), nil
+	case "fish":
+		return filepath.Join(config, "fish", "config.fish"), nil
+	case "pwsh":
+		return filepath.Join(config, "powershell", "Microsoft.PowerShell_profile.ps1"), nil
+	case "elvish":
+		return filepath.Join(config, "elvish", "rc.elv"), nil
+	case "xonsh":
+		return filepath.Join(home, ".xonshrc"), nil
+	}
+	return "", fmt.Errorf("can't install for %s; load the output of try init --shell %s from its config yourself", shellName, shellName)
+}
+
+// InitLine is the line that loads `bin init --shell shellName args...` into
+// shellName at startup.
+func InitLine(shellName, bin string, args []string) string {
+	words := append([]string{bin, "init", "--shell", shellName}, args...)
+	quote := shellQuote
+	switch shellName {
+	case "fish":
+		quote = Fish.Quote
+	case "pwsh":
+		quote = Pwsh.Quote
+	case "elvish":
+		quote = Elvish.Quote
+	case "xonsh":
+		// Strings in subprocess mode are Python literals.
+		quote = strconv.Quote
+	}
+	for i, w := range words {
+		if w == "" || strings.ContainsAny(w, " \t'\"\\$`*?[]{}()<>|&;#~") {
+			words[i] = quote(w)
+		}
+	}
+	cmd := strings.Join(words, " ")
+
+	switch shellName {
+	case "fish":
+		return cmd + " | source"
+	case "pwsh":
+		return "Invoke-Expression (& " + cmd + " | Out-String)"
+	case "elvish":
+		if words[0] != bin {
+			// e: only takes a bare word.
+			cmd = "(external " + words[0] + ") " + strings.Join(words[1:], " ")
+			return "eval (" + cmd + " | slurp)"
+		}
+		return "eval (e:" + cmd + " | slurp)"
+	case "xonsh":
+		return "execx($(" + cmd + "))"
+	}
+	return `eval "$(` + cmd + `)"`
+}
+
+// Install puts line into path inside try's block, replacing an existing block
+// in place or appending a new one. It reports whether the file changed.
+func Install(path, line string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	content := string(data)
+	block := blockStart + "\n" + line + "\n" + blockEnd + "\n"
+
+	var updated string
+	if start, end, ok := findBlock(content); ok {
+		updated = content[:start] + block + content[end:]
+	} else {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		updated = content + block
+	}
+	if updated == string(data) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	return true, writeKeepingMode(path, updated)
+}
+
+// Uninstall removes try's block from path, reporting whether there was one.
+func Uninstall(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	content := string(data)
+
+	start, end, ok := findBlock(content)
+	if !ok {
+		return false, nil
+	}
+	before := content[:start]
+	// Drop the blank line Install put in front of the block.
+	if strings.HasSuffix(before, "\n\n") {
+		before = before[:len(before)-1]
+	}
+	return true, writeKeepingMode(path, before+content[end:])
+}
+
+// findBlock locates try's block, including the newline after its end marker.
+func findBlock(content string) (start, end int, ok bool) {
+	start = strings.Index(content, blockStart+"\n")
+	if start < 0 {
+		return 0, 0, false
+	}
+	rel := strings.Index(content[start:], blockEnd)
+	if rel < 0 {
+		return 0, 0, false
+	}
+	end = start + rel + len(blockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return start, end, true
+}
+
+func writeKeepingMode(path, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return os.WriteFile(path, []byte(content), mode)
+}
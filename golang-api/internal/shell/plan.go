@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Step is one entry of a dry-run plan.
+type Step struct {
+	Action string   `json:"action"`
+	Path   string   `json:"path,omitempty"`
+	Args   []string `json:"args,omitempty"`
+	Msg    string   `json:"message,omitempty"`
+}
+
+// Plan describes what tasks would do, without doing any of it.
+type Plan struct {
+	Target string `json:"target"`
+	Name   string `json:"name"`
+	Steps  []Step `json:"steps"`
+}
+
+// NewPlan describes tasks for a shell speaking e, which decides the
+// on-enter script a cd would source.
+func NewPlan(e Emitter, tasks []Task) Plan {
+	var plan Plan
+	for _, t := range tasks {
+		if t.Type == "target" {
+			plan.Target = t.Path
+			plan.Name = filepath.Base(t.Path)
+			break
+		}
+	}
+
+	for _, t := range tasks {
+		switch t.Type {
+		case "target":
+		case "echo":
+			plan.Steps = append(plan.Steps, Step{Action: "echo", Msg: t.Msg})
+		case "git-clone":
+			args := append(append([]string{"git", "clone"}, cloneArgs(t)...), t.URI, plan.Target)
+			plan.Steps = append(plan.Steps, Step{Action: "git", Args: args})
+			if len(t.Sparse) > 0 {
+				args = append([]string{"git", "-C", plan.Target, "sparse-checkout", "set"}, t.Sparse...)
+				plan.Steps = append(plan.Steps, Step{Action: "git", Args: args})
+			}
+			if t.Ref != "" {
+				args = []string{"git", "-C", plan.Target, "checkout", "--quiet", t.Ref}
+				plan.Steps = append(plan.Steps, Step{Action: "git", Args: args})
+			}
+		case "git-worktree":
+			repo := t.Repo
+			if repo == "" {
+				repo = "."
+			}
+			if t.Fetch != "" {
+				args := []string{"git", "-C", repo, "fetch", "--quiet", t.Remote, t.Fetch}
+				plan.Steps = append(plan.Steps, Step{Action: "git", Args: args})
+			}
+			args := append([]string{"git", "-C", repo, "worktree", "add"}, worktreeAddArgs(t, plan.Target)...)
+			plan.Steps = append(plan.Steps, Step{Action: "git", Args: args})
+		case "action":
+			plan.Steps = append(plan.Steps, Step{Action: "action", Path: plan.Target, Args: []string{t.Cmd}})
+		case "cd":
+			path := t.Path
+			if path == "" {
+				path = plan.Target
+			}
+			plan.Steps = append(plan.Steps, Step{Action: "cd", Path: path})
+			envFile, onEnter := enterFiles(e, plan.Target)
+			if envFile != "" {
+				plan.Steps = append(plan.Steps, Step{Action: "env", Path: envFile})
+			}
+			if onEnter != "" {
+				plan.Steps = append(plan.Steps, Step{Action: "source", Path: onEnter})
+			}
+		default:
+			path := t.Path
+			if path == "" {
+				path = plan.Target
+			}
+			plan.Steps = append(plan.Steps, Step{Action: t.Type, Path: path})
+		}
+	}
+	return plan
+}
+
+func (p Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+func (p Plan) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Dry run, nothing was changed.\n\n")
+	fmt.Fprintf(w, "  target  %s\n", p.Target)
+	fmt.Fprintf(w, "  name    %s\n\n", p.Name)
+	for _, s := range p.Steps {
+		detail := s.Path
+		switch {
+		case s.Action == "git":
+			detail = displayArgs(s.Args)
+		case s.Action == "action":
+			detail = fmt.Sprintf("%s (in %s)", strings.Join(s.Args, " "), s.Path)
+		case s.Msg != "":
+			detail = s.Msg
+		}
+		fmt.Fprintf(w, "  %-7s %s\n", s.Action, detail)
+	}
+}
+
+// displayArgs quotes only the arguments that need it, for reading rather
+// than pasting into a shell.
+func displayArgs(args []string) string {
+	shown := make([]string, len(args))
+	for i, arg := range args {
+		shown[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~") {
+			shown[i] = shellQuote(arg)
+		}
+	}
+	return strings.Join(shown, " ")
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// CheckNesting fails when tasks would cd from inside a subshell that
+// RunWithoutWrapper started, rather than stacking another one on top.
+func CheckNesting(tasks []Task) error {
+	dir := os.Getenv("TRY_SUBSHELL")
+	if dir == "" {
+		return nil
+	}
+	for _, t := range tasks {
+		if t.Type == "cd" {
+			return fmt.Errorf("already in a try subshell (%s); exit it first, or run try init --install so try can cd in place", dir)
+		}
+	}
+	return nil
+}
+
+// RunWithoutWrapper carries out what is left after Execute when no shell
+// function is there to take it: editors and actions run right here, and a cd
+// starts $SHELL inside the try, the way nix-shell does. The try is done when
+// that shell exits.
+func RunWithoutWrapper(tasks []Task) error {
+	targetPath, err := targetOf(tasks)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tasks {
+		switch t.Type {
+		case "echo":
+			if t.Msg != "" {
+				fmt.Fprintln(os.Stderr, t.Msg)
+			}
+		case "print":
+			fmt.Println(targetPath)
+		case "edit":
+			if err := runInteractive(exec.Command("sh", "-c", editScript, "sh", targetPath)); err != nil {
+				return err
+			}
+		case "action":
+			if err := runInteractive(exec.Command("sh", "-c", actionScript(t.Cmd), "sh", targetPath)); err != nil {
+				return err
+			}
+		case "cd":
+			dir := t.Path
+			if dir == "" {
+				dir = targetPath
+			}
+			if err := Subshell(dir, entered(tasks)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entered returns the variables of the env tasks that follow a cd. An
+// on-enter script is written for the shell function's shell, which may not
+// be $SHELL; only the variables carry over.
+func entered(tasks []Task) []string {
+	var env []string
+	for _, t := range tasks {
+		switch t.Type {
+		case "env":
+			env = append(env, t.Env...)
+		case "source":
+			fmt.Fprintf(os.Stderr, "Skipping %s; it runs only through the shell function.\n", t.Path)
+		}
+	}
+	return env
+}
+
+// Subshell runs the user's $SHELL (sh if unset) in dir, with env added to
+// the environment, until it exits.
+func Subshell(dir string, env []string) error {
+	sh := os.Getenv("SHELL")
+	if sh == "" {
+		sh = "/bin/sh"
+	}
+
+	fmt.Fprintf(os.Stderr, "Starting %s in %s; exit it to come back.\n", sh, dir)
+	fmt.Fprintln(os.Stderr, `Tip: add eval "$(try init)" to your shell's rc file so try can cd in place (see try --help).`)
+
+	cmd := exec.Command(sh)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), env...), "TRY_SUBSHELL="+dir)
+	err := runInteractive(cmd)
+	// How the shell exits is up to the user.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil
+	}
+	return err
+}
+
+func runInteractive(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
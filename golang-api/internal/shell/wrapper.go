@@ -0,0 +1,348 @@
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Shells lists the shells Wrapper can generate a function for.
+var Shells = []string{"bash", "zsh", "fish", "pwsh", "nu", "elvish", "xonsh"}
+
+// Wrapper returns the shell function that runs bin and lets the calling shell
+// act on its output, for `try init`.
+//
+// Each wrapper passes --dialect and --protocol, sets TRY_WRAPPED=1 for the
+// binary, and carries out the directives (see WriteDirectives) of output
+// starting with the matching header. Other output is printed as is; a header with another version is
+// ignored, as the binary warns about it. PowerShell, Nushell, Elvish and
+// Xonsh all reserve "try" as a keyword, so their function is called
+// "tryout". Nushell and Xonsh can't eval a command line of their own; they
+// ask for POSIX and run exec directives with sh; Xonsh sources on-enter
+// scripts with source-bash, and Nushell skips them.
+func Wrapper(shellName, bin, triesPath string) (string, error) {
+	switch shellName {
+	case "bash", "zsh":
+		return bashWrapper(bin, triesPath), nil
+	case "fish":
+		return fishWrapper(bin, triesPath), nil
+	case "pwsh":
+		return pwshWrapper(bin, triesPath), nil
+	case "nu":
+		return nuWrapper(bin, triesPath), nil
+	case "elvish":
+		return elvishWrapper(bin, triesPath), nil
+	case "xonsh":
+		return xonshWrapper(bin, triesPath), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (expected %s)", shellName, strings.Join(Shells, ", "))
+}
+
+// header is the first line of directive output this version understands.
+var header = fmt.Sprintf("%s %d", DirectiveHeader, ProtocolVersion)
+
+// binArgs are the arguments the bash and fish functions pass, quoted by e.
+func binArgs(e Emitter, dialect, triesPath string) string {
+	args := fmt.Sprintf(" --dialect %s --protocol %d", dialect, ProtocolVersion)
+	if triesPath == "" {
+		return args
+	}
+	return " --path " + e.Quote(triesPath) + args
+}
+
+func bashWrapper(bin, triesPath string) string {
+	binArgs := binArgs(POSIX, "posix", triesPath)
+	return fmt.Sprintf(`try() {
+  script_path=%s
+  # Check if first argument is a known command
+  case "$1" in
+    exec)
+      # Run in the foreground so output streams and the exit code survives
+      /usr/bin/env TRY_WRAPPED=1 "$script_path"%s "$@"
+      return $?
+      ;;
+    clone|worktree|init|completion|help|--help|-h)
+      out=$(/usr/bin/env TRY_WRAPPED=1 "$script_path"%s "$@" 2>/dev/tty)
+      ;;
+    *)
+      out=$(/usr/bin/env TRY_WRAPPED=1 "$script_path" cd%s "$@" 2>/dev/tty)
+      ;;
+  esac
+  rc=$?
+  case "$out" in
+    '%s'|'%s'"
+"*) ;;
+    '%s '*) return 1 ;;
+    *)
+      [ -n "$out" ] && printf '%%s\n' "$out"
+      return $rc
+      ;;
+  esac
+  # Directives come in on fd 3 so that exec keeps the terminal as stdin.
+  while IFS= read -r line <&3; do
+    case "$line" in
+      "cd "*) cd -- "${line#cd }" || return ;;
+      "env "*) export -- "${line#env }" ;;
+      "source "*) . "${line#source }" || return ;;
+      "echo "*) printf '%%s\n' "${line#echo }" ;;
+      "exec "*)
+        cmd=${line#exec }
+        eval "$cmd" || return
+        ;;
+    esac
+  done 3<<TRY_DIRECTIVES
+$out
+TRY_DIRECTIVES
+  return $rc
+}
+`, POSIX.Quote(bin), binArgs, binArgs, binArgs, header, header, DirectiveHeader)
+}
+
+func fishWrapper(bin, triesPath string) string {
+	binArgs := binArgs(Fish, "fish", triesPath)
+	return fmt.Sprintf(`function try
+  set -l script_path %s
+  set -l out
+  # Check if first argument is a known command
+  switch $argv[1]
+    case exec
+      # Run in the foreground so output streams and the exit code survives
+      /usr/bin/env TRY_WRAPPED=1 $script_path%s $argv
+      return $status
+    case clone worktree init completion help --help -h
+      set out (/usr/bin/env TRY_WRAPPED=1 $script_path%s $argv 2>/dev/tty | string collect)
+    case '*'
+      set out (/usr/bin/env TRY_WRAPPED=1 $script_path cd%s $argv 2>/dev/tty | string collect)
+  end
+  set -l rc $status
+  set out (string split \n -- $out)
+  switch "$out[1]"
+    case '%s'
+    case '%s *'
+      return 1
+    case '*'
+      test -n "$out"; and printf '%%s\n' $out
+      return $rc
+  end
+  for line in $out[2..-1]
+    switch $line
+      case 'cd *'
+        cd (string sub -s 4 -- $line); or return
+      case 'env *'
+        set -l kv (string split -m 1 = -- (string sub -s 5 -- $line))
+        set -gx $kv[1] $kv[2]
+      case 'source *'
+        source (string sub -s 8 -- $line); or return
+      case 'echo *'
+        printf '%%s\n' (string sub -s 6 -- $line)
+      case 'exec *'
+        eval (string sub -s 6 -- $line); or return
+    end
+  end
+  return $rc
+end
+`, Fish.Quote(bin), binArgs, binArgs, binArgs, header, DirectiveHeader)
+}
+
+func pwshWrapper(bin, triesPath string) string {
+	return fmt.Sprintf(`function tryout {
+  $tryBin = %s
+  $tryArgs = @(%s)
+  $first = if ($args.Count -gt 0) { $args[0] } else { '' }
+  if ($first -in @('exec', 'init', 'completion')) {
+    & $tryBin @tryArgs @args
+    return
+  }
+  $env:TRY_WRAPPED = '1'
+  try {
+    if ($first -in @('clone', 'worktree', 'help', '--help', '-h')) {
+      $out = @(& $tryBin @tryArgs @args)
+    } else {
+      $out = @(& $tryBin @tryArgs cd @args)
+    }
+  } finally {
+    Remove-Item Env:TRY_WRAPPED -ErrorAction Ignore
+  }
+  if ($out.Count -eq 0) { return }
+  if ($out[0] -cne %s) {
+    if (-not $out[0].StartsWith(%s)) { $out }
+    return
+  }
+  foreach ($line in ($out | Select-Object -Skip 1)) {
+    if ($line.StartsWith('cd ')) {
+      Set-Location -LiteralPath $line.Substring(3)
+    } elseif ($line.StartsWith('env ')) {
+      $kv = $line.Substring(4).Split('=', 2)
+      Set-Item -LiteralPath "env:$($kv[0])" -Value $kv[1]
+    } elseif ($line.StartsWith('source ')) {
+      . $line.Substring(7)
+    } elseif ($line.StartsWith('echo ')) {
+      Write-Output $line.Substring(5)
+    } elseif ($line.StartsWith('exec ')) {
+      Invoke-Expression $line.Substring(5)
+    }
+  }
+}
+`, Pwsh.Quote(bin), pwshBinArgs(triesPath), Pwsh.Quote(header), Pwsh.Quote(DirectiveHeader+" "))
+}
+
+func nuWrapper(bin, triesPath string) string {
+	return fmt.Sprintf(`def --env --wrapped tryout [...args] {
+  let bin = %s
+  let base = [%s]
+  let first = ($args | get -i 0 | default "")
+  if $first in ['exec' 'init' 'completion'] {
+    ^$bin ...$base ...$args
+    return
+  }
+  let out = if $first in ['clone' 'worktree' 'help' '--help' '-h'] {
+    do -i { with-env {TRY_WRAPPED: '1'} { ^$bin ...$base ...$args } }
+  } else {
+    do -i { with-env {TRY_WRAPPED: '1'} { ^$bin ...$base cd ...$args } }
+  }
+  let lines = ($out | default "" | lines)
+  let first_line = ($lines | get -i 0 | default "")
+  if $first_line != %s {
+    if not ($first_line | str starts-with %s) and ($out | is-not-empty) { print $out }
+    return
+  }
+  # exec directives get the try as an argument, so cd can wait until the end.
+  mut target = ""
+  mut vars = {}
+  for line in ($lines | skip 1) {
+    if ($line | str starts-with 'cd ') {
+      $target = ($line | str substring 3..)
+    } else if ($line | str starts-with 'env ') {
+      let kv = ($line | str substring 4.. | split row -n 2 '=')
+      $vars = ($vars | upsert $kv.0 ($kv | get -i 1 | default ""))
+    } else if ($line | str starts-with 'source ') {
+      print -e $"Skipping ($line | str substring 7..); Nushell can't source it."
+    } else if ($line | str starts-with 'echo ') {
+      print ($line | str substring 5..)
+    } else if ($line | str starts-with 'exec ') {
+      ^sh -c ($line | str substring 5..)
+    }
+  }
+  load-env $vars
+  if $target != "" { cd $target }
+}
+`, nuQuote(bin), nuBinArgs(triesPath), nuQuote(header), nuQuote(DirectiveHeader+" "))
+}
+
+func elvishWrapper(bin, triesPath string) string {
+	return fmt.Sprintf(`use str
+fn tryout {|@args|
+  var bin = %s
+  var base = [%s]
+  var first = ''
+  if (> (count $args) 0) { set first = $args[0] }
+  if (has-value [exec init completion] $first) {
+    (external $bin) $@base $@args
+    return
+  }
+  if (not (has-value [clone worktree help --help -h] $first)) { set base = [$@base cd] }
+  tmp E:TRY_WRAPPED = 1
+  var lines = []
+  var ok = ?(set lines = [((external $bin) $@base $@args)])
+  if (== (count $lines) 0) { return }
+  if (or (not $ok) (!=s $lines[0] %s)) {
+    if (not (str:has-prefix $lines[0] %s)) { for line $lines { echo $line } }
+    return
+  }
+  for line $lines[1..] {
+    if (str:has-prefix $line 'cd ') {
+      cd $line[3..]
+    } elif (str:has-prefix $line 'env ') {
+      var kv = [(str:split &max=2 '=' $line[4..])]
+      set-env $kv[0] $kv[1]
+    } elif (str:has-prefix $line 'source ') {
+      eval (slurp < $line[7..])
+    } elif (str:has-prefix $line 'echo ') {
+      echo $line[5..]
+    } elif (str:has-prefix $line 'exec ') {
+      eval $line[5..]
+    }
+  }
+}
+`, Elvish.Quote(bin), elvishBinArgs(triesPath), Elvish.Quote(header), Elvish.Quote(DirectiveHeader+" "))
+}
+
+func xonshWrapper(bin, triesPath string) string {
+	return fmt.Sprintf(`from xonsh.tools import unthreadable as _try_unthreadable
+
+@_try_unthreadable
+def _tryout(args):
+    import subprocess
+    from xonsh.dirstack import cd
+    base = [%s%s]
+    first = args[0] if args else ''
+    if first in ('exec', 'init', 'completion'):
+        return subprocess.call(base + list(args))
+    cmd = base + list(args) if first in ('clone', 'worktree', 'help', '--help', '-h') else base + ['cd'] + list(args)
+    env = dict(__xonsh__.env.detype(), TRY_WRAPPED='1')
+    proc = subprocess.run(cmd, stdout=subprocess.PIPE, text=True, env=env)
+    lines = proc.stdout.rstrip('\n').split('\n')
+    if lines[0] != %s:
+        if proc.stdout and not lines[0].startswith(%s):
+            print(proc.stdout, end='')
+        return proc.returncode
+    for line in lines[1:]:
+        if line.startswith('cd '):
+            cd([line[3:]])
+        elif line.startswith('env '):
+            key, _, value = line[4:].partition('=')
+            __xonsh__.env[key] = value
+        elif line.startswith('source '):
+            aliases['source-bash']([line[7:]])
+        elif line.startswith('echo '):
+            print(line[5:])
+        elif line.startswith('exec '):
+            rc = subprocess.call(['sh', '-c', line[5:]])
+            if rc:
+                return rc
+    return proc.returncode
+
+aliases['tryout'] = _tryout
+`, strconv.Quote(bin), pyBinArgs(triesPath), strconv.Quote(header), strconv.Quote(DirectiveHeader+" "))
+}
+
+func pwshBinArgs(triesPath string) string {
+	args := fmt.Sprintf("'--dialect', 'pwsh', '--protocol', '%d'", ProtocolVersion)
+	if triesPath == "" {
+		return args
+	}
+	return "'--path', " + Pwsh.Quote(triesPath) + ", " + args
+}
+
+// nuQuote uses a raw string, which can hold anything but its own delimiter.
+func nuQuote(s string) string {
+	hashes := "#"
+	for strings.Contains(s, "'"+hashes) {
+		hashes += "#"
+	}
+	return "r" + hashes + "'" + s + "'" + hashes
+}
+
+func nuBinArgs(triesPath string) string {
+	args := fmt.Sprintf("'--dialect' 'posix' '--protocol' '%d'", ProtocolVersion)
+	if triesPath == "" {
+		return args
+	}
+	return "'--path' " + nuQuote(triesPath) + " " + args
+}
+
+func elvishBinArgs(triesPath string) string {
+	args := fmt.Sprintf("--dialect elvish --protocol %d", ProtocolVersion)
+	if triesPath == "" {
+		return args
+	}
+	return "--path " + Elvish.Quote(triesPath) + " " + args
+}
+
+func pyBinArgs(triesPath string) string {
+	args := fmt.Sprintf(", '--dialect', 'posix', '--protocol', '%d'", ProtocolVersion)
+	if triesPath == "" {
+		return args
+	}
+	return ", '--path', " + strconv.Quote(triesPath) + args
+}
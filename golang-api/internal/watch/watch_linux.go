@@ -0,0 +1,60 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const mask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// Watcher signals on Events whenever an entry directly inside one of the
+// watched roots is created, removed, renamed or touched. Bursts of changes
+// are coalesced into a single pending event.
+type Watcher struct {
+	Events chan struct{}
+	file   *os.File
+}
+
+func New(roots ...string) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, root := range roots {
+		if _, err := unix.InotifyAddWatch(fd, root, mask); err != nil {
+			unix.Close(fd)
+			return nil, err
+		}
+	}
+
+	// Wrapping the non-blocking fd in an os.File hands it to the runtime
+	// poller, which lets Close interrupt the pending Read.
+	w := &Watcher{
+		Events: make(chan struct{}, 1),
+		file:   os.NewFile(uintptr(fd), "inotify"),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *Watcher) loop() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		if _, err := w.file.Read(buf); err != nil {
+			return
+		}
+		select {
+		case w.Events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *Watcher) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+type Watcher struct {
+	Events chan struct{}
+}
+
+func New(roots ...string) (*Watcher, error) {
+	return nil, errors.New("filesystem watching is only supported on linux")
+}
+
+func (w *Watcher) Close() error {
+	return nil
+}
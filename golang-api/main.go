@@ -3,13 +3,20 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/tobi/try/golang-api/internal/completion"
+	"github.com/tobi/try/golang-api/internal/config"
 	"github.com/tobi/try/golang-api/internal/git"
+	"github.com/tobi/try/golang-api/internal/naming"
 	"github.com/tobi/try/golang-api/internal/selector"
 	"github.com/tobi/try/golang-api/internal/shell"
+	"golang.org/x/term"
 )
 
 const version = "0.1.0-golang"
@@ -22,13 +29,35 @@ func main() {
 		os.Exit(0)
 	}
 
-	if len(os.Args) == 1 {
+	if len(os.Args) == 1 && !runsDirectly(0) {
 		printGlobalHelp()
 		os.Exit(2)
 	}
 
 	args := os.Args[1:]
-	triesPath := extractOptionWithValue(&args, "--path")
+
+	// Completion runs on every TAB: answer it before config is read, and
+	// leave its words, flags and all, to Complete.
+	if words, pathArg, ok := completeRequest(args); ok {
+		for _, candidate := range completion.Complete(words, completionTriesPath(words, pathArg)) {
+			fmt.Println(candidate)
+		}
+		os.Exit(0)
+	}
+
+	// Everything after "--" belongs to the command run by `try exec` and must
+	// not be mistaken for our own options.
+	var passthrough []string
+	for i, arg := range args {
+		if arg == "--" {
+			passthrough = args[i:]
+			args = args[:i:i]
+			break
+		}
+	}
+
+	pathArg := extractOptionWithValue(&args, "--path")
+	triesPath := pathArg
 	if triesPath == "" {
 		if envPath := os.Getenv("TRY_PATH"); envPath != "" {
 			triesPath = envPath
@@ -38,6 +67,27 @@ func main() {
 	}
 	triesPath = expandPath(triesPath)
 
+	emitScript := hasFlag(&args, "--emit-script")
+	dryRun := ""
+	if hasFlag(&args, "--dry-run") {
+		dryRun = "text"
+	} else if format := extractOptionWithValue(&args, "--dry-run"); format != "" {
+		dryRun = format
+	}
+	if dryRun != "" && dryRun != "text" && dryRun != "json" {
+		fmt.Fprintf(os.Stderr, "Error: --dry-run takes text or json, got %q\n", dryRun)
+		os.Exit(2)
+	}
+	emitter, err := shell.EmitterFor(extractOptionWithValue(&args, "--dialect"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --dialect: %v\n", err)
+		os.Exit(2)
+	}
+	protocol := 0
+	if version := extractOptionWithValue(&args, "--protocol"); version != "" {
+		protocol, _ = strconv.Atoi(version)
+		warnStaleWrapper(protocol)
+	}
 	andType := extractOptionWithValue(&args, "--and-type")
 	andExit := hasFlag(&args, "--and-exit")
 	andKeysRaw := extractOptionWithValue(&args, "--and-keys")
@@ -48,28 +98,55 @@ func main() {
 		andKeys = parseTestKeys(andKeysRaw)
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring config: %v\n", err)
+	}
+	git.SetAliases(cfg.Aliases)
+	if cfg.DefaultHost != "" {
+		git.SetDefaultHost(cfg.DefaultHost)
+	}
+
+	args = append(args, passthrough...)
+
 	command := ""
 	if len(args) > 0 {
 		command = args[0]
 		args = args[1:]
 	}
 
+	// The shell function sends everything it doesn't know to cd, and so does
+	// a direct run, where nothing at all opens the selector.
+	if runsDirectly(protocol) && !isCommand(command) {
+		if command != "" {
+			args = append([]string{command}, args...)
+		}
+		command = "cd"
+	}
+	if command == "cd" && len(args) > 0 && (args[0] == "--help" || args[0] == "-h") {
+		command = "help"
+	}
+
 	switch command {
-	case "clone":
-		tasks := cmdClone(args, triesPath)
-		shell.EmitTasksScript(tasks)
+	case "help", "--help", "-h":
+		printGlobalHelp()
 		os.Exit(0)
+	case "clone":
+		tasks := cmdClone(args, triesPath, cfg, dryRun != "")
+		os.Exit(runTasks(tasks, emitter, emitScript, dryRun, protocol))
 	case "init":
-		cmdInit(args, triesPath)
-		os.Exit(0)
+		os.Exit(cmdInit(args, triesPath, pathArg != ""))
+	case "completion":
+		os.Exit(cmdCompletion(args, triesPath))
 	case "worktree":
-		tasks := cmdWorktree(args, triesPath)
-		shell.EmitTasksScript(tasks)
-		os.Exit(0)
+		tasks := cmdWorktree(args, triesPath, cfg, dryRun != "")
+		os.Exit(runTasks(tasks, emitter, emitScript, dryRun, protocol))
+	case "exec":
+		os.Exit(cmdExec(args, triesPath, cfg.Naming, andKeys))
 	case "cd":
-		tasks := cmdCd(args, triesPath, andType, andConfirm, andExit, andKeys)
+		tasks := cmdCd(args, triesPath, cfg, dryRun != "", andType, andConfirm, andExit, andKeys)
 		if tasks != nil {
-			shell.EmitTasksScript(tasks)
+			os.Exit(runTasks(tasks, emitter, emitScript, dryRun, protocol))
 		}
 		os.Exit(0)
 	default:
@@ -79,6 +156,136 @@ func main() {
 	}
 }
 
+// runTasks carries out tasks and prints only what the calling shell still has
+// to do, usually a cd. With emitScript the whole plan is printed as a script
+// for the shell to run instead, as older versions did; with dryRun ("text" or
+// "json") it is only described. Scripts are written in emitter's dialect;
+// with a protocol the shell function gets directives instead. Run straight
+// from a terminal, without the shell function, it opens a subshell in the try.
+func runTasks(tasks []shell.Task, emitter shell.Emitter, emitScript bool, dryRun string, protocol int) int {
+	if err := shell.Validate(tasks); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	switch {
+	case dryRun == "json":
+		shell.NewPlan(emitter, tasks).WriteJSON(os.Stdout)
+		return 0
+	case dryRun != "":
+		shell.NewPlan(emitter, tasks).WriteText(os.Stdout)
+		return 0
+	case emitScript:
+		if err := shell.Emit(emitter, tasks); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	unwrapped := runsDirectly(protocol)
+	if unwrapped {
+		if err := shell.CheckNesting(tasks); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			releaseReserved(tasks)
+			return 1
+		}
+	}
+
+	rest, err := shell.Execute(emitter, tasks)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if unwrapped {
+		if err := shell.RunWithoutWrapper(rest); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	if protocol != 0 {
+		if err := shell.WriteDirectives(os.Stdout, emitter, rest); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	// Shell functions from before --protocol only eval output chained with
+	// &&, so the try is touched again to keep the cd from being echoed.
+	// Scripts reading the output get the same, without the warning.
+	if oldWrapper() {
+		fmt.Fprintln(os.Stderr, "Warning: your try shell function is out of date; reload it with try init")
+	}
+	legacy := append([]shell.Task{rest[0], {Type: "touch"}}, rest[1:]...)
+	if err := shell.Emit(emitter, legacy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runsDirectly reports whether a person runs try without the shell function.
+// The shell functions from try init mark themselves with TRY_WRAPPED; those
+// from before it capture stdout, so a terminal there means nobody would act
+// on the output.
+func runsDirectly(protocol int) bool {
+	return protocol == 0 && os.Getenv("TRY_WRAPPED") == "" && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// givenArgs keeps the command line as given; option parsing rearranges
+// os.Args in place.
+var givenArgs = append([]string{}, os.Args[1:]...)
+
+// oldWrapper reports whether a shell function from before --protocol runs
+// try: those pass --path first (after cd, for queries), send stderr to the
+// terminal and capture stdout.
+func oldWrapper() bool {
+	args := givenArgs
+	if len(args) > 0 && args[0] == "cd" {
+		args = args[1:]
+	}
+	return len(args) >= 2 && args[0] == "--path" &&
+		term.IsTerminal(int(os.Stderr.Fd())) && !term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// isCommand reports whether command is one of try's own rather than a query.
+func isCommand(command string) bool {
+	switch command {
+	case "help", "--help", "-h", "clone", "init", "completion", "worktree", "exec", "cd":
+		return true
+	}
+	return false
+}
+
+// releaseReserved removes a try that newTryTasks reserved but nothing ran
+// in; os.Remove leaves it alone if it isn't empty after all.
+func releaseReserved(tasks []shell.Task) {
+	var target string
+	for _, t := range tasks {
+		switch {
+		case t.Type == "target":
+			target = t.Path
+		case t.Type == "mkdir" && t.Fresh && target != "":
+			os.Remove(target)
+		}
+	}
+}
+
+// warnStaleWrapper tells the user when the shell function from `try init`
+// and this binary disagree on the directive protocol. The function ignores
+// directives it doesn't understand, so nothing happens until it is reloaded.
+func warnStaleWrapper(protocol int) {
+	switch {
+	case protocol < shell.ProtocolVersion:
+		fmt.Fprintf(os.Stderr, "Warning: your try shell function is out of date (protocol %d, try speaks %d); reload it with try init\n",
+			protocol, shell.ProtocolVersion)
+	case protocol > shell.ProtocolVersion:
+		fmt.Fprintf(os.Stderr, "Warning: try is older than your shell function (protocol %d, try speaks %d); update try\n",
+			protocol, shell.ProtocolVersion)
+	}
+}
+
 func printGlobalHelp() {
 	tryPath := os.Getenv("TRY_PATH")
 	if tryPath == "" {
@@ -89,8 +296,9 @@ func printGlobalHelp() {
 
 Lightweight experiments for people with ADHD
 
-this tool is not meant to be used directly,
-but added to your ~/.zshrc or ~/.bashrc:
+run directly, try starts a new shell in the chosen try (exit it to come
+back). To cd in place instead, run try init --install, or add it to your
+~/.zshrc or ~/.bashrc yourself:
 
   eval "$(try init ~/src/tries)"
 
@@ -98,13 +306,47 @@ for fish shell, add to ~/.config/fish/config.fish:
 
   eval (try init ~/src/tries | string collect)
 
+PowerShell, Nushell, Elvish and Xonsh reserve "try" as a keyword, so there
+the function is called tryout (pick the shell with --shell):
+
+  Invoke-Expression (& try init --shell pwsh ~/src/tries | Out-String)   # $PROFILE
+  ^try init --shell nu ~/src/tries | save -f ~/.config/nushell/try.nu    # then source it
+  eval (e:try init --shell elvish ~/src/tries | slurp)                   # rc.elv
+  execx($(try init --shell xonsh ~/src/tries))                           # .xonshrc
+
 Usage:
 
-  init [--path PATH]  # Initialize shell function for aliasing
+  init [--path PATH] [--shell NAME]  # Initialize shell function for aliasing;
+                     # NAME is bash, zsh, fish, pwsh, nu, elvish or xonsh
+    --bind KEY             # Also open the selector with KEY (ctrl-t, alt-c, ...)
+                           # at any bash, zsh or fish prompt
+    --install              # Add the init line to your shell's rc file, or
+                           # update it there; --uninstall takes it out
   cd [QUERY] [name?]  # Interactive selector; Git URL shorthand supported
   clone <git-uri> [name]  # Clone git repo into date-prefixed directory
+    --depth N              # Shallow clone
+    --branch REF           # Check out REF instead of the default branch
+    --recurse-submodules   # Clone submodules too
+    --filter SPEC          # Partial clone, e.g. --filter=blob:none
+    --sparse [PATHS]       # Sparse checkout of comma-separated PATHS, or of
+                           # the subdirectory a tree/blob URL points into
   worktree dir [name]  # Create date-prefixed dir; add worktree from CWD if git repo
   worktree <repo-path> [name]  # Same as above, but source repo is <repo-path>
+    --branch NAME          # Create branch try/NAME instead of detaching
+    --checkout BRANCH      # Check out an existing branch
+    --ref COMMIT           # Start at COMMIT or tag instead of HEAD
+    --pr N                 # Start at pull request N (GitLab: merge request)
+  exec <query> -- <cmd...>  # Run a command inside the best-matching try
+  completion bash|zsh|fish  # Print tab completions (init includes them)
+
+  --emit-script  # Print mkdir/git steps as a script for the shell to run
+                 # instead of running them, like older versions did
+  --dry-run[=json]  # Describe what clone, worktree, creating or deleting a
+                    # try would do without doing it
+  --dialect SHELL   # Write output for posix (default), fish, pwsh or elvish;
+                    # set by the init wrappers
+  --protocol N      # Answer the init wrappers with cd/env/source/echo/exec
+                    # directives instead of a script
 
 Clone Examples:
 
@@ -112,11 +354,23 @@ Clone Examples:
   # Creates: 2025-08-27-tobi-try
 
   try clone https://github.com/tobi/try.git my-fork
-  # Creates: my-fork
+  # Creates: 2025-08-27-my-fork
 
   try https://github.com/tobi/try.git
   # Shorthand for clone (same as first example)
 
+  try clone gh:big/monorepo --filter=blob:none --sparse pkg/server
+  # Fetches only what pkg/server needs from a huge monorepo
+
+  try https://github.com/tobi/try/tree/main/golang-api --sparse
+  # Clones the main branch, checks out only golang-api and cds into it
+
+  try tobi/try
+  try gh:tobi/try
+  try gl:group/sub/project
+  # Repository shorthands; bare user/repo uses the default host (github.com)
+  # and opens the try named user-repo instead, e.g. an earlier clone, if any
+
 Worktree Examples:
 
   try worktree dir
@@ -125,6 +379,59 @@ Worktree Examples:
   try worktree ~/src/github.com/tobi/try my-branch
   # From given repo path, creates: 2025-08-27-my-branch and adds detached worktree
 
+  try . --branch retry-logic
+  # Worktree on a new branch try/retry-logic, so commits aren't left detached
+
+  try worktree ~/src/github.com/tobi/try --pr 123
+  # Fetches pull request 123 from origin, creates: 2025-08-27-try-pr-123
+
+Exec Examples:
+
+  try exec redis-pool -- go test ./...
+  # Runs the tests inside the matching try; your shell stays where it is
+
+Entering a Try:
+
+  Whenever the shell function cds into a try, it exports the KEY=VALUE lines
+  of the try's .try/env and sources its .try/on-enter script (on-enter.fish,
+  on-enter.ps1 or on-enter.elv for those shells):
+
+  echo 'GOFLAGS=-race' >> .try/env
+  echo 'nvm use 20' > .try/on-enter
+
+  Both are skipped when they are tracked by a cloned repository or sit in
+  another one, such as a submodule, and a subshell gets only the variables.
+
+Selector Keys:
+
+  Enter   cd into the selected try, or create a new one
+  Ctrl-O  open the selected try in $EDITOR without changing directory
+  Ctrl-Y  print the selected try's path
+  Ctrl-D  delete the selected try
+
+Config:
+
+  ~/.config/try/config.json (override with TRY_CONFIG). Custom selector actions
+  run their command inside the selected try:
+
+  {"actions": [{"key": "ctrl-g", "name": "lazygit", "command": "lazygit"}]}
+
+  Shorthand aliases (gh, gl, bb, cb and srht are built in) and the host used
+  for bare user/repo can be configured too:
+
+  {"aliases": {"work": "git@git.internal.example:"}, "default_host": "gl"}
+
+  Branches made by worktree --branch are prefixed with "try/" by default:
+
+  {"worktree_branch_prefix": "me/"}
+
+  Names of new tries follow one policy, whether created from the selector, a
+  worktree or a clone. Formats take {date}, {name}, {owner} and {repo}; slug is
+  keep or lower; collision is version, suffix, reuse or error:
+
+  {"naming": {"format": "{date}-{name}", "clone_format": "{date}-{owner}-{repo}",
+              "date_layout": "2006-01-02", "slug": "keep", "collision": "version"}}
+
 Defaults:
   Default path: ` + TRY_PATH_DEFAULT + ` (override with --path on commands)
   Current default: ` + tryPath + `
@@ -141,6 +448,43 @@ func expandPath(path string) string {
 	return absPath
 }
 
+// completeRequest picks the words to complete out of
+// "try [--path DIR] __complete WORDS...".
+func completeRequest(args []string) (words []string, triesPath string, ok bool) {
+	for i, arg := range args {
+		if arg == "__complete" {
+			before := append([]string{}, args[:i]...)
+			triesPath = extractOptionWithValue(&before, "--path")
+			return args[i+1:], triesPath, len(before) == 0
+		}
+	}
+	return nil, "", false
+}
+
+// completionTriesPath resolves the tries to complete from, honouring a
+// --path typed earlier on the command line being completed.
+func completionTriesPath(words []string, triesPath string) string {
+	if len(words) > 0 {
+		var typed []string
+		for _, w := range words[:len(words)-1] {
+			if w == "--" {
+				break
+			}
+			typed = append(typed, w)
+		}
+		if path := extractOptionWithValue(&typed, "--path"); path != "" {
+			triesPath = path
+		}
+	}
+	if triesPath == "" {
+		triesPath = os.Getenv("TRY_PATH")
+	}
+	if triesPath == "" {
+		triesPath = TRY_PATH_DEFAULT
+	}
+	return expandPath(triesPath)
+}
+
 func extractOptionWithValue(args *[]string, optName string) string {
 	for i := len(*args) - 1; i >= 0; i-- {
 		arg := (*args)[i]
@@ -172,225 +516,494 @@ func hasFlag(args *[]string, flag string) bool {
 	return false
 }
 
-func cmdClone(args []string, triesPath string) []shell.Task {
-	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "Error: git URI required for clone command")
-		fmt.Fprintln(os.Stderr, "Usage: try clone <git-uri> [name]")
-		os.Exit(1)
+// parseCloneFlags pulls the git clone options out of args and returns them on
+// a git-clone task; the caller fills in the URI.
+func parseCloneFlags(args *[]string) (shell.Task, error) {
+	task := shell.Task{Type: "git-clone"}
+
+	depth, err := extractRequiredOption(args, "--depth")
+	if err != nil {
+		return task, err
+	}
+	if depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n <= 0 {
+			return task, fmt.Errorf("--depth must be a positive number, got %q", depth)
+		}
+		task.Depth = n
 	}
+	if task.Branch, err = extractRequiredOption(args, "--branch"); err != nil {
+		return task, err
+	}
+	if task.Filter, err = extractRequiredOption(args, "--filter"); err != nil {
+		return task, err
+	}
+	task.RecurseSubmodules = hasFlag(args, "--recurse-submodules")
 
-	gitURI := args[0]
-	var customName string
-	if len(args) > 1 {
-		customName = args[1]
+	// --sparse takes comma-separated paths, but may also stand alone to mean
+	// "the subdirectory the URL points into". A following argument is only
+	// taken as its value when it isn't another flag or a full repository URL.
+	rest := (*args)[:0:0]
+	for i := 0; i < len(*args); i++ {
+		arg := (*args)[i]
+		var paths string
+		switch {
+		case strings.HasPrefix(arg, "--sparse="):
+			paths = strings.TrimPrefix(arg, "--sparse=")
+		case arg == "--sparse":
+			if i+1 < len(*args) && !strings.HasPrefix((*args)[i+1], "-") && git.ParseGitURI((*args)[i+1]) == nil {
+				i++
+				paths = (*args)[i]
+			}
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+
+		task.SparseCheckout = true
+		for _, p := range strings.Split(paths, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				task.Sparse = append(task.Sparse, p)
+			}
+		}
 	}
+	*args = rest
+	return task, nil
+}
 
-	dirName := git.GenerateCloneDirectoryName(gitURI, customName)
-	if dirName == "" {
+// extractRequiredOption is extractOptionWithValue for options that can't go
+// without their value: a missing one is an error rather than "".
+func extractRequiredOption(args *[]string, optName string) (string, error) {
+	for i := len(*args) - 1; i >= 0; i-- {
+		arg := (*args)[i]
+		if arg == optName {
+			if i+1 == len(*args) || strings.HasPrefix((*args)[i+1], "-") {
+				return "", fmt.Errorf("%s needs a value", optName)
+			}
+			value := (*args)[i+1]
+			*args = append((*args)[:i], (*args)[i+2:]...)
+			return value, nil
+		}
+		if strings.HasPrefix(arg, optName+"=") {
+			value := strings.TrimPrefix(arg, optName+"=")
+			if value == "" {
+				return "", fmt.Errorf("%s needs a value", optName)
+			}
+			*args = append((*args)[:i], (*args)[i+1:]...)
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+// cloneTasks builds the task list for cloning gitURI into a new try. Links to
+// a ref or subdirectory check out that ref and cd into the subdirectory.
+func cloneTasks(gitURI, customName, triesPath string, policy naming.Policy, dryRun bool, cloneTask shell.Task) []shell.Task {
+	parsed := git.ParseGitURI(gitURI)
+	if parsed == nil {
 		fmt.Fprintf(os.Stderr, "Error: Unable to parse git URI: %s\n", gitURI)
 		os.Exit(1)
 	}
 
-	fullPath := filepath.Join(triesPath, dirName)
+	if parsed.Ref != "" && cloneTask.Branch == "" && cloneTask.Ref == "" {
+		// --branch only takes branch and tag names; commits are checked out
+		// after the clone.
+		if regexp.MustCompile(`^[0-9a-f]{7,40}$`).MatchString(parsed.Ref) {
+			cloneTask.Ref = parsed.Ref
+		} else {
+			cloneTask.Branch = parsed.Ref
+		}
+	}
+	if parsed.Subpath != "" {
+		if cloneTask.SparseCheckout && len(cloneTask.Sparse) == 0 {
+			cloneTask.Sparse = []string{parsed.Subpath}
+		}
+	}
+	if cloneTask.SparseCheckout && len(cloneTask.Sparse) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: --sparse needs paths unless the URL points into a subdirectory")
+		os.Exit(1)
+	}
+
+	cloneTask.URI = git.CloneURL(gitURI)
+	// Checked again by runTasks, but by then the try would be reserved.
+	if err := shell.Validate([]shell.Task{cloneTask}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	tasks := newTryTasks(triesPath, policy, naming.Vars{Name: customName, Owner: parsed.Owner, Repo: parsed.Repo}, dryRun)
+	cdPath := filepath.Join(tasks[0].Path, filepath.FromSlash(parsed.Subpath))
+	return append(tasks,
+		shell.Task{Type: "echo", Msg: fmt.Sprintf("Using git clone to create this trial from %s.", cloneTask.URI)},
+		cloneTask,
+		shell.Task{Type: "touch"},
+		shell.Task{Type: "cd", Path: cdPath},
+	)
+}
+
+// newTryTasks names a new try and, unless this is a dry run, reserves its
+// directory right away so that concurrent invocations can't pick the same one.
+func newTryTasks(triesPath string, policy naming.Policy, vars naming.Vars, dryRun bool) []shell.Task {
+	var dirName string
+	var fresh bool
+	var err error
+	if dryRun {
+		dirName, err = policy.Resolve(triesPath, vars)
+	} else {
+		dirName, fresh, err = policy.Reserve(triesPath, vars)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	return []shell.Task{
-		{Type: "target", Path: fullPath},
-		{Type: "mkdir"},
-		{Type: "echo", Msg: fmt.Sprintf("Using git clone to create this trial from %s.", gitURI)},
-		{Type: "git-clone", URI: gitURI},
-		{Type: "touch"},
-		{Type: "cd"},
+		{Type: "target", Path: filepath.Join(triesPath, dirName)},
+		{Type: "mkdir", Fresh: fresh},
 	}
 }
 
-func cmdInit(args []string, triesPath string) {
+func cmdClone(args []string, triesPath string, cfg *config.Config, dryRun bool) []shell.Task {
+	cloneTask, err := parseCloneFlags(&args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: git URI required for clone command")
+		fmt.Fprintln(os.Stderr, "Usage: try clone <git-uri> [name] [clone options]")
+		os.Exit(1)
+	}
+
+	gitURI := git.ExpandShorthand(args[0])
+	var customName string
+	if len(args) > 1 {
+		customName = args[1]
+	}
+
+	return cloneTasks(gitURI, customName, triesPath, cfg.Naming, dryRun, cloneTask)
+}
+
+// cmdInit prints the shell function, or installs the line loading it. With
+// pathGiven, triesPath came from --path and is kept in that line too.
+func cmdInit(args []string, triesPath string, pathGiven bool) int {
 	scriptPath, _ := filepath.Abs(os.Args[0])
 
+	shellName := extractOptionWithValue(&args, "--shell")
+	if shellName == "" {
+		shellName = detectShell()
+	}
+	bindKey := extractOptionWithValue(&args, "--bind")
+	install := hasFlag(&args, "--install")
+	uninstall := hasFlag(&args, "--uninstall")
+
+	// Only what was given here is repeated in the rc file, so that --path
+	// and TRY_PATH keep working there.
+	var initArgs []string
 	if len(args) > 0 && strings.HasPrefix(args[0], "/") {
 		triesPath = filepath.Clean(args[0])
+		initArgs = append(initArgs, triesPath)
 		args = args[1:]
+	} else if pathGiven {
+		initArgs = append(initArgs, "--path", triesPath)
+	}
+	if bindKey != "" {
+		initArgs = append(initArgs, "--bind", bindKey)
 	}
 
-	pathArg := ""
-	if triesPath != "" {
-		pathArg = fmt.Sprintf(` --path "%s"`, triesPath)
-	}
-
-	if isFish() {
-		fishScript := fmt.Sprintf(`function try
-  set -l script_path "%s"
-  # Check if first argument is a known command
-  switch $argv[1]
-    case clone worktree init
-      set -l cmd (/usr/bin/env %s%s $argv 2>/dev/tty | string collect)
-    case '*'
-      set -l cmd (/usr/bin/env %s cd%s $argv 2>/dev/tty | string collect)
-  end
-  set -l rc $status
-  if test $rc -eq 0
-    if string match -r ' && ' -- $cmd
-      eval $cmd
-    else
-      printf %%s $cmd
-    end
-  else
-    printf %%s $cmd
-  end
-end
-`, scriptPath, scriptPath, pathArg, scriptPath, pathArg)
-		fmt.Print(fishScript)
-	} else {
-		bashScript := fmt.Sprintf(`try() {
-  script_path='%s'
-  # Check if first argument is a known command
-  case "$1" in
-    clone|worktree|init)
-      cmd=$(/usr/bin/env "$script_path"%s "$@" 2>/dev/tty)
-      ;;
-    *)
-      cmd=$(/usr/bin/env "$script_path" cd%s "$@" 2>/dev/tty)
-      ;;
-  esac
-  rc=$?
-  if [ $rc -eq 0 ]; then
-    case "$cmd" in
-      *" && "*) eval "$cmd" ;;
-      *) printf %%s "$cmd" ;;
-    esac
-  else
-    printf %%s "$cmd"
-  fi
-}
-`, scriptPath, pathArg, pathArg)
-		fmt.Print(bashScript)
-	}
-}
-
-func cmdWorktree(args []string, triesPath string) []shell.Task {
+	wrapper, err := shell.Wrapper(shellName, scriptPath, triesPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	binding := ""
+	if bindKey != "" {
+		if binding, err = shell.Binding(shellName, bindKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	if install || uninstall {
+		return installInit(shellName, initArgs, uninstall)
+	}
+
+	fmt.Print(wrapper)
+	if completions, err := completion.Script(shellName, scriptPath, triesPath); err == nil {
+		fmt.Print(completions)
+	}
+	fmt.Print(binding)
+	return 0
+}
+
+// installInit adds the line loading `try init` to the rc file of shellName,
+// or takes it out again. Repeated installs update the line in place.
+func installInit(shellName string, initArgs []string, uninstall bool) int {
+	rc, err := shell.RcFile(shellName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if uninstall {
+		removed, err := shell.Uninstall(rc)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		case removed:
+			fmt.Printf("Removed try from %s; open a new shell to drop it.\n", rc)
+		default:
+			fmt.Printf("try isn't installed in %s.\n", rc)
+		}
+		return 0
+	}
+
+	changed, err := shell.Install(rc, shell.InitLine(shellName, rcBinary(), initArgs))
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	case changed:
+		fmt.Printf("Installed try in %s; open a new shell to use it.\n", rc)
+	default:
+		fmt.Printf("try is already installed in %s.\n", rc)
+	}
+	return 0
+}
+
+// rcBinary is how the rc file should call this binary: plain "try" when that
+// is what PATH finds, the full path otherwise.
+func rcBinary() string {
+	self, err := os.Executable()
+	if err != nil {
+		return "try"
+	}
+	if found, err := exec.LookPath("try"); err == nil {
+		a, errA := os.Stat(found)
+		b, errB := os.Stat(self)
+		if errA == nil && errB == nil && os.SameFile(a, b) {
+			return "try"
+		}
+	}
+	return self
+}
+
+func cmdWorktree(args []string, triesPath string, cfg *config.Config, dryRun bool) []shell.Task {
+	worktreeTask := parseWorktreeFlags(&args, cfg)
+
 	sub := ""
 	if len(args) > 0 {
 		sub = args[0]
 		args = args[1:]
 	}
-
-	var base, repoDir string
-	cwd, _ := os.Getwd()
+	custom := strings.Join(args, " ")
 
 	if sub == "" || sub == "dir" {
-		custom := strings.Join(args, " ")
-		if custom != "" {
-			base = strings.ReplaceAll(custom, " ", "-")
-		} else {
-			base = filepath.Base(cwd)
-		}
-		repoDir = cwd
-	} else {
-		repoDir = filepath.Clean(sub)
-		custom := strings.Join(args, " ")
-		if custom != "" {
-			base = strings.ReplaceAll(custom, " ", "-")
-		} else {
-			base = filepath.Base(repoDir)
-		}
+		cwd, _ := os.Getwd()
+		return worktreeTasks(cwd, custom, triesPath, cfg.Naming, dryRun, worktreeTask)
 	}
+	repoDir := filepath.Clean(sub)
+	worktreeTask.Repo = repoDir
+	return worktreeTasks(repoDir, custom, triesPath, cfg.Naming, dryRun, worktreeTask)
+}
 
-	datePrefix := time.Now().Format("2006-01-02")
-	base = shell.ResolveUniqueNameWithVersioning(triesPath, datePrefix, base)
-	dirName := datePrefix + "-" + base
-	fullPath := filepath.Join(triesPath, dirName)
+// parseWorktreeFlags extracts --branch, --checkout and --ref. New branches get
+// the configured prefix unless they already carry it.
+func parseWorktreeFlags(args *[]string, cfg *config.Config) shell.Task {
+	task := shell.Task{Type: "git-worktree"}
 
-	tasks := []shell.Task{
-		{Type: "target", Path: fullPath},
-		{Type: "mkdir"},
+	// Without its value, a flag would silently leave the worktree detached.
+	required := func(name string) string {
+		value, err := extractRequiredOption(args, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return value
 	}
-
-	gitDir := filepath.Join(repoDir, ".git")
-	if _, err := os.Stat(gitDir); err == nil {
-		tasks = append(tasks, shell.Task{Type: "echo", Msg: fmt.Sprintf("Using git worktree to create this trial from %s.", repoDir)})
-		if sub == "" || sub == "dir" {
-			tasks = append(tasks, shell.Task{Type: "git-worktree"})
-		} else {
-			tasks = append(tasks, shell.Task{Type: "git-worktree", Repo: repoDir})
+	task.NewBranch = required("--branch")
+	task.Checkout = required("--checkout")
+	task.Ref = required("--ref")
+	if pr := required("--pr"); pr != "" {
+		if n, err := strconv.Atoi(strings.TrimPrefix(pr, "#")); err != nil || n <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: --pr must be a pull request number, got %q\n", pr)
+			os.Exit(1)
 		}
+		if task.Checkout != "" || task.Ref != "" {
+			fmt.Fprintln(os.Stderr, "Error: --pr already picks the commit; drop --checkout and --ref")
+			os.Exit(1)
+		}
+		task.Fetch = strings.TrimPrefix(pr, "#")
 	}
 
-	tasks = append(tasks, shell.Task{Type: "touch"})
-	tasks = append(tasks, shell.Task{Type: "cd"})
-	return tasks
+	if task.NewBranch != "" && task.Checkout != "" {
+		fmt.Fprintln(os.Stderr, "Error: --branch and --checkout can't be combined")
+		os.Exit(1)
+	}
+	if task.Checkout != "" && task.Ref != "" {
+		fmt.Fprintln(os.Stderr, "Error: --checkout already picks the commit; drop --ref")
+		os.Exit(1)
+	}
+	if prefix := cfg.BranchPrefix(); task.NewBranch != "" && !strings.HasPrefix(task.NewBranch, prefix) {
+		task.NewBranch = prefix + task.NewBranch
+	}
+	return task
 }
 
-func cmdCd(args []string, triesPath string, andType, andConfirm string, andExit bool, andKeys []string) []shell.Task {
-	if len(args) > 0 && args[0] == "clone" {
-		return cmdClone(args[1:], triesPath)
+// pullRequestFetch turns the pull request number in task.Fetch into a refspec
+// fetching its head from repoDir's remote into a local ref, which the worktree
+// then starts at. GitLab calls them merge requests and keeps them elsewhere.
+func pullRequestFetch(repoDir string, task *shell.Task) error {
+	remotes, err := exec.Command("git", "-C", repoDir, "remote").Output()
+	if err != nil {
+		return fmt.Errorf("can't list remotes of %s: %w", repoDir, err)
 	}
-
-	if len(args) > 0 && (args[0] == "." || args[0] == "./") {
-		pathArg := args[0]
-		custom := ""
-		if len(args) > 1 {
-			custom = strings.Join(args[1:], " ")
+	names := strings.Fields(string(remotes))
+	if len(names) == 0 {
+		return fmt.Errorf("%s has no remote to fetch pull requests from", repoDir)
+	}
+	remote := names[0]
+	for _, name := range names {
+		if name == "origin" {
+			remote = name
 		}
+	}
 
-		cwd, _ := os.Getwd()
-		repoDir := filepath.Clean(filepath.Join(cwd, pathArg))
+	source, err := pullRequestRef(repoDir, remote, task.Fetch)
+	if err != nil {
+		return err
+	}
 
-		base := ""
-		if custom != "" {
-			base = strings.ReplaceAll(custom, " ", "-")
-		} else {
-			base = filepath.Base(repoDir)
+	task.Remote = remote
+	task.Ref = "refs/try/pr/" + task.Fetch
+	task.Fetch = "+" + source + ":" + task.Ref
+	return nil
+}
+
+// pullRequestRef asks remote which of the GitHub and GitLab refs for pull
+// request n it has, as self-hosted GitLab can live on any hostname. When the
+// remote can't be reached, the hostname decides, and the fetch will tell.
+func pullRequestRef(repoDir, remote, n string) (string, error) {
+	pull := "refs/pull/" + n + "/head"
+	merge := "refs/merge-requests/" + n + "/head"
+
+	out, err := exec.Command("git", "-C", repoDir, "ls-remote", remote, pull, merge).Output()
+	if err != nil {
+		url, _ := exec.Command("git", "-C", repoDir, "remote", "get-url", remote).Output()
+		if parsed := git.ParseGitURI(strings.TrimSpace(string(url))); parsed != nil && strings.Contains(parsed.Host, "gitlab") {
+			return merge, nil
+		}
+		return pull, nil
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && (fields[1] == pull || fields[1] == merge) {
+			return fields[1], nil
 		}
+	}
+	return "", fmt.Errorf("%s has no pull or merge request %s", remote, n)
+}
 
-		datePrefix := time.Now().Format("2006-01-02")
-		base = shell.ResolveUniqueNameWithVersioning(triesPath, datePrefix, base)
-		dirName := datePrefix + "-" + base
-		fullPath := filepath.Join(triesPath, dirName)
+// worktreeTasks creates a try named after custom, or repoDir's basename, and
+// adds worktreeTask's worktree when repoDir is a git repository. An empty
+// worktreeTask.Repo means the repository around the cwd.
+func worktreeTasks(repoDir, custom, triesPath string, policy naming.Policy, dryRun bool, worktreeTask shell.Task) []shell.Task {
+	_, err := os.Stat(filepath.Join(repoDir, ".git"))
+	isRepo := err == nil
+	if !isRepo && (worktreeTask.NewBranch != "" || worktreeTask.Checkout != "" || worktreeTask.Ref != "") {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a git repository\n", repoDir)
+		os.Exit(1)
+	}
 
-		tasks := []shell.Task{
-			{Type: "target", Path: fullPath},
-			{Type: "mkdir"},
+	name := custom
+	if name == "" {
+		name = filepath.Base(repoDir)
+	}
+	if worktreeTask.Fetch != "" {
+		pr := worktreeTask.Fetch
+		if err := pullRequestFetch(repoDir, &worktreeTask); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-
-		gitDir := filepath.Join(repoDir, ".git")
-		if _, err := os.Stat(gitDir); err == nil {
-			tasks = append(tasks, shell.Task{Type: "echo", Msg: fmt.Sprintf("Using git worktree to create this trial from %s.", repoDir)})
-			tasks = append(tasks, shell.Task{Type: "git-worktree", Repo: repoDir})
+		if custom == "" {
+			name += "-pr-" + pr
 		}
+	}
+	if err := shell.Validate([]shell.Task{worktreeTask}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	tasks := newTryTasks(triesPath, policy, naming.Vars{Name: name}, dryRun)
 
-		tasks = append(tasks, shell.Task{Type: "touch"})
-		tasks = append(tasks, shell.Task{Type: "cd"})
-		return tasks
+	if isRepo {
+		tasks = append(tasks, shell.Task{Type: "echo", Msg: fmt.Sprintf("Using git worktree to create this trial from %s.", repoDir)})
+		tasks = append(tasks, worktreeTask)
 	}
 
-	if len(args) > 0 && git.IsGitURI(args[0]) {
-		gitURI := args[0]
-		var customName string
-		if len(args) > 1 {
-			customName = strings.Join(args[1:], " ")
-		}
+	tasks = append(tasks, shell.Task{Type: "touch"})
+	tasks = append(tasks, shell.Task{Type: "cd"})
+	return tasks
+}
 
-		dirName := git.GenerateCloneDirectoryName(gitURI, customName)
-		if dirName == "" {
-			fmt.Fprintf(os.Stderr, "Error: Unable to parse git URI: %s\n", gitURI)
-			os.Exit(1)
-		}
+// isCloneTarget reports whether args name a repository once any clone
+// flags are set aside. Their errors are left for cmdClone to report.
+func isCloneTarget(args []string) bool {
+	rest := append([]string{}, args...)
+	parseCloneFlags(&rest)
+	return len(rest) > 0 && git.IsGitURI(rest[0])
+}
 
-		fullPath := filepath.Join(triesPath, dirName)
-		return []shell.Task{
-			{Type: "target", Path: fullPath},
-			{Type: "mkdir"},
-			{Type: "echo", Msg: fmt.Sprintf("Using git clone to create this trial from %s.", gitURI)},
-			{Type: "git-clone", URI: gitURI},
-			{Type: "touch"},
-			{Type: "cd"},
+// localQuery returns the selector query for a bare user/repo when a try is
+// named after it, such as an earlier clone: without its date, the name is
+// user-repo or ends in -user-repo.
+func localQuery(args []string, triesPath string, dryRun bool) (string, bool) {
+	if len(args) != 1 || !git.IsBareShorthand(args[0]) {
+		return "", false
+	}
+	query := strings.ReplaceAll(args[0], "/", "-")
+	sel := selector.NewTrySelector(query, triesPath, map[string]interface{}{"dry_run": dryRun})
+	for _, try := range sel.LoadAllTries() {
+		name := undated(try.Basename)
+		if name == query || strings.HasSuffix(name, "-"+query) {
+			return query, true
 		}
 	}
+	return "", false
+}
+
+func cmdCd(args []string, triesPath string, cfg *config.Config, dryRun bool, andType, andConfirm string, andExit bool, andKeys []string) []shell.Task {
+	if len(args) > 0 && args[0] == "clone" {
+		return cmdClone(args[1:], triesPath, cfg, dryRun)
+	}
+
+	if len(args) > 0 && (args[0] == "." || args[0] == "./") {
+		cwd, _ := os.Getwd()
+		repoDir := filepath.Clean(filepath.Join(cwd, args[0]))
+		args = args[1:]
+		worktreeTask := parseWorktreeFlags(&args, cfg)
+		worktreeTask.Repo = repoDir
+		return worktreeTasks(repoDir, strings.Join(args, " "), triesPath, cfg.Naming, dryRun, worktreeTask)
+	}
 
 	searchTerm := strings.Join(args, " ")
+
+	// Clone flags only mean something next to a repository; anything else
+	// is a query for the selector. A bare user/repo only clones when no try
+	// is named after it; gh:user/repo always does.
+	if query, ok := localQuery(args, triesPath, dryRun); ok {
+		searchTerm = query
+	} else if isCloneTarget(args) {
+		return cmdClone(args, triesPath, cfg, dryRun)
+	}
+
 	options := map[string]interface{}{
 		"test_render_once": andExit,
 		"test_no_cls":      andExit || len(andKeys) > 0,
 		"test_keys":        andKeys,
 		"test_confirm":     andConfirm,
+		"actions":          cfg.Actions,
+		"naming":           cfg.Naming,
+		"dry_run":          dryRun,
 	}
 	if andType != "" {
 		options["initial_input"] = andType
@@ -415,8 +1028,18 @@ func cmdCd(args []string, triesPath string, andType, andConfirm string, andExit
 
 	tasks := []shell.Task{{Type: "target", Path: result["path"].(string)}}
 
-	if result["type"] == "mkdir" {
-		tasks = append(tasks, shell.Task{Type: "mkdir"})
+	switch result["type"] {
+	case "mkdir":
+		fresh, _ := result["fresh"].(bool)
+		tasks = append(tasks, shell.Task{Type: "mkdir", Fresh: fresh})
+	case "edit", "print":
+		// Leave the working directory alone; just mark the try as used.
+		return append(tasks, shell.Task{Type: "touch"}, shell.Task{Type: result["type"].(string)})
+	case "action":
+		return append(tasks, shell.Task{Type: "touch"}, shell.Task{Type: "action", Cmd: result["command"].(string)})
+	case "delete":
+		// Only returned for dry runs; the selector deletes by itself otherwise.
+		return append(tasks, shell.Task{Type: "delete"})
 	}
 
 	tasks = append(tasks, shell.Task{Type: "touch"})
@@ -425,6 +1048,89 @@ func cmdCd(args []string, triesPath string, andType, andConfirm string, andExit
 	return tasks
 }
 
+func cmdExec(args []string, triesPath string, policy naming.Policy, andKeys []string) int {
+	sep := -1
+	for i, arg := range args {
+		if arg == "--" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || sep == len(args)-1 {
+		fmt.Fprintln(os.Stderr, "Error: command required for exec")
+		fmt.Fprintln(os.Stderr, "Usage: try exec <query> -- <cmd...>")
+		return 2
+	}
+
+	query := strings.Join(args[:sep], " ")
+	command := args[sep+1:]
+
+	path := resolveTry(query, triesPath, policy, andKeys)
+	if path == "" {
+		return 1
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = path
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 127
+	}
+	return 0
+}
+
+// resolveTry picks the try matching query using the selector's ranking. A
+// sole match or an exact name wins outright; anything else is ambiguous and
+// goes through the interactive selector, where a new try may be created for
+// the command as well.
+func resolveTry(query, triesPath string, policy naming.Policy, andKeys []string) string {
+	options := map[string]interface{}{
+		"test_no_cls": len(andKeys) > 0,
+		"test_keys":   andKeys,
+		"naming":      policy,
+	}
+	sel := selector.NewTrySelector(query, triesPath, options)
+
+	tries := sel.GetTries()
+	if len(tries) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no try matches %q\n", query)
+		return ""
+	}
+	if len(tries) == 1 {
+		return tries[0].Path
+	}
+	name := strings.ReplaceAll(query, " ", "-")
+	for _, try := range tries {
+		if try.Basename == name || undated(try.Basename) == name {
+			return try.Path
+		}
+	}
+
+	result := sel.Run()
+	if result == nil {
+		return ""
+	}
+	switch result["type"] {
+	case "cd", "mkdir":
+		// The selector has already made the directory of a new try.
+		return result["path"].(string)
+	}
+	return ""
+}
+
+// undated strips the date prefix from a try's name.
+func undated(name string) string {
+	return regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-`).ReplaceAllString(name, "")
+}
+
 func parseTestKeys(spec string) []string {
 	if spec == "" {
 		return nil
@@ -462,12 +1168,18 @@ func parseTestKeys(spec string) []string {
 			keys = append(keys, "\n")
 		case "CTRL-K", "CTRLK":
 			keys = append(keys, "\x0B")
+		case "CTRL-O", "CTRLO":
+			keys = append(keys, "\x0F")
+		case "CTRL-Y", "CTRLY":
+			keys = append(keys, "\x19")
 		default:
 			if strings.HasPrefix(tokUpper, "TYPE=") {
 				text := tok[5:]
 				for _, ch := range text {
 					keys = append(keys, string(ch))
 				}
+			} else if seq, err := config.KeySequence(tok); err == nil {
+				keys = append(keys, seq)
 			} else if len(tok) == 1 {
 				keys = append(keys, tok)
 			}
@@ -477,7 +1189,34 @@ func parseTestKeys(spec string) []string {
 	return keys
 }
 
-func isFish() bool {
-	shell := os.Getenv("SHELL")
-	return strings.Contains(shell, "fish")
+func cmdCompletion(args []string, triesPath string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: shell required")
+		fmt.Fprintln(os.Stderr, "Usage: try completion bash|zsh|fish")
+		return 2
+	}
+
+	scriptPath, _ := filepath.Abs(os.Args[0])
+	script, err := completion.Script(args[0], scriptPath, triesPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	fmt.Print(script)
+	return 0
+}
+
+// detectShell guesses the interactive shell from $SHELL; anything unknown
+// gets the POSIX wrapper, which bash and zsh share.
+func detectShell() string {
+	name := filepath.Base(os.Getenv("SHELL"))
+	switch {
+	case strings.Contains(name, "fish"):
+		return "fish"
+	case name == "pwsh" || name == "powershell":
+		return "pwsh"
+	case name == "nu" || name == "elvish" || name == "xonsh" || name == "zsh":
+		return name
+	}
+	return "bash"
 }
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tobi/try/golang-api/internal/naming"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(cfg, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestCloneCustomNameGetsDatePrefix(t *testing.T) {
+	dir := t.TempDir()
+	today := time.Now().Format("2006-01-02")
+
+	stdout, _, _ := runCmd(t, "clone", "https://github.com/tobi/try.git", "my-fork", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, filepath.Join(dir, today+"-my-fork")) {
+		t.Errorf("custom clone names should follow the naming format, got %q", stdout)
+	}
+}
+
+func TestCloneCollisionGetsSuffix(t *testing.T) {
+	dir := t.TempDir()
+	name := time.Now().Format("2006-01-02") + "-tobi-try"
+	os.MkdirAll(filepath.Join(dir, name), 0755)
+
+	stdout, _, _ := runCmd(t, "clone", "https://github.com/tobi/try.git", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, filepath.Join(dir, name+"-2")) {
+		t.Errorf("clone into an existing name should get a -2 suffix, got %q", stdout)
+	}
+}
+
+func TestCreateNewVersionsExistingName(t *testing.T) {
+	dir := t.TempDir()
+	name := time.Now().Format("2006-01-02") + "-test1"
+	os.MkdirAll(filepath.Join(dir, name), 0755)
+
+	stdout, _, _ := runCmd(t, "cd", "--and-type", "test1", "--and-keys", "DOWN,DOWN,ENTER", "--emit-script", "--path", dir)
+	if !strings.Contains(stdout, "mkdir -p '"+filepath.Join(dir, time.Now().Format("2006-01-02")+"-test2")+"'") {
+		t.Errorf("create new should bump the version instead of reusing the directory, got %q", stdout)
+	}
+}
+
+func TestNamingConfigFormatAndSlug(t *testing.T) {
+	dir := t.TempDir()
+	cfg := writeConfig(t, `{"naming": {"format": "{name}-{date}", "date_layout": "20060102", "slug": "lower"}}`)
+
+	stdout, _, _ := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "worktree", "dir", "My Experiment", "--path", dir)
+	want := regexp.MustCompile(`my-experiment-\d{8}'`)
+	if !want.MatchString(stdout) {
+		t.Errorf("configured format and slug should be applied, got %q", stdout)
+	}
+}
+
+func TestNamingCollisionError(t *testing.T) {
+	dir := t.TempDir()
+	cfg := writeConfig(t, `{"naming": {"format": "{name}", "collision": "error"}}`)
+	os.MkdirAll(filepath.Join(dir, "taken"), 0755)
+
+	_, stderr, err := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "worktree", "dir", "taken", "--path", dir)
+	if err == nil {
+		t.Fatal("collision=error should fail on an existing name")
+	}
+	if !strings.Contains(stderr, "already exists") {
+		t.Errorf("should explain the collision, got %q", stderr)
+	}
+}
+
+func TestNamingConfigIgnoredWhenInvalid(t *testing.T) {
+	dir := t.TempDir()
+	cfg := writeConfig(t, `{"naming": {"format": "{name}", "collision": "overwrite"}}`)
+	os.MkdirAll(filepath.Join(dir, "taken"), 0755)
+
+	stdout, _, err := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "worktree", "dir", "taken", "--path", dir)
+	if err != nil {
+		t.Fatalf("an invalid config should be ignored, not fatal: %v", err)
+	}
+	if !strings.Contains(stdout, "-taken'") || strings.Contains(stdout, filepath.Join(dir, "taken")+"'") {
+		t.Errorf("should fall back to the default naming policy, got %q", stdout)
+	}
+}
+
+func TestReserveNeverHandsOutTheSameName(t *testing.T) {
+	dir := t.TempDir()
+	policy := naming.Policy{}
+
+	const workers = 20
+	names := make(chan string, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, fresh, err := policy.Reserve(dir, naming.Vars{Name: "race"})
+			if err != nil || !fresh {
+				t.Errorf("reserve failed: %v (fresh=%v)", err, fresh)
+			}
+			names <- name
+		}()
+	}
+	wg.Wait()
+	close(names)
+
+	seen := map[string]bool{}
+	for name := range names {
+		if seen[name] {
+			t.Errorf("%s was handed out twice", name)
+		}
+		seen[name] = true
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != workers {
+		t.Errorf("expected %d directories, got %d", workers, len(entries))
+	}
+}
+
+func TestConcurrentWorktreesGetDistinctTries(t *testing.T) {
+	tries := t.TempDir()
+	repo := t.TempDir()
+
+	const runs = 5
+	outputs := make([]string, runs)
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outputs[i], _, _ = runCmdInDir(t, repo, "worktree", "dir", "tmux", "--path", tries)
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, out := range outputs {
+		if seen[out] {
+			t.Errorf("two invocations were sent to the same try: %q", out)
+		}
+		seen[out] = true
+	}
+	if entries, _ := os.ReadDir(tries); len(entries) != runs {
+		t.Errorf("expected %d tries, got %d", runs, len(entries))
+	}
+}
+
+func TestDryRunDoesNotReserve(t *testing.T) {
+	dir := t.TempDir()
+	runCmd(t, "worktree", "dir", "planned", "--dry-run", "--path", dir)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Error("a dry run should not reserve a directory")
+	}
+}
+
+func TestCreateNewRejectsControlCharacters(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, _ := runCmd(t, "cd", "a\nb\x1b[2J", "--and-keys", "ENTER", "--path", dir)
+	if strings.Contains(stdout, "cd ") {
+		t.Errorf("should not cd into a name with control characters, got %q", stdout)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("nothing should be created, found %d entries", len(entries))
+	}
+}
+
+func TestRenderDoesNotExpandPlaceholdersInValues(t *testing.T) {
+	now := time.Date(2025, 8, 14, 0, 0, 0, 0, time.UTC)
+	policy := naming.Policy{CloneFormat: "{owner}-{repo}-{date}"}
+	for i := 0; i < 20; i++ {
+		got := policy.Render(naming.Vars{Owner: "{repo}", Repo: "{date}"}, now)
+		if got != "{repo}-{date}-2025-08-14" {
+			t.Fatalf("values should be inserted as they are, got %q", got)
+		}
+	}
+	if got := policy.Render(naming.Vars{Repo: "try"}, now); got != "try-2025-08-14" {
+		t.Errorf("an empty owner should take its dash along, got %q", got)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tobi/try/golang-api/internal/shell"
+)
+
+// runWrapper sources the bash function from try init and runs script with it.
+// Tests have no terminal, so the function's stderr goes to /dev/null.
+func runWrapper(t *testing.T, tries, script string) string {
+	t.Helper()
+	wrapper, _, err := runCmd(t, "init", "--shell", "bash", tries)
+	if err != nil {
+		t.Fatalf("init should succeed: %v", err)
+	}
+	wrapper = strings.ReplaceAll(wrapper, "/dev/tty", "/dev/null")
+
+	cmd := exec.Command("bash", "-c", wrapper+"\n"+script)
+	cmd.Dir = t.TempDir()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+func TestProtocolWritesDirectives(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-old-experiment")
+	os.MkdirAll(path, 0755)
+
+	stdout, _, _ := runCmd(t, "--protocol", "1", "cd", "old", "--and-keys", "ENTER", "--path", dir)
+	if stdout != "#try 1\ncd "+path+"\n" {
+		t.Errorf("should write a header and a cd directive, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmd(t, "--protocol", "1", "cd", "--and-keys", "CTRL-Y", "--path", dir)
+	if stdout != "#try 1\necho "+path+"\n" {
+		t.Errorf("Ctrl-Y should become an echo directive, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmd(t, "--protocol", "1", "cd", "--and-keys", "CTRL-O", "--path", dir)
+	if !strings.HasPrefix(stdout, "#try 1\nexec /usr/bin/env sh -c 'exec ${EDITOR:-vi} \"$1\"' sh ") {
+		t.Errorf("Ctrl-O should become an exec directive, got %q", stdout)
+	}
+}
+
+func TestProtocolLeavesOtherOutputAlone(t *testing.T) {
+	stdout, _, _ := runCmd(t, "--protocol", "1", "--dry-run", "--path", t.TempDir(), "clone", "https://github.com/tobi/try.git")
+	if strings.HasPrefix(stdout, "#try") || !strings.Contains(stdout, "Dry run") {
+		t.Errorf("dry runs should print their plan, got %q", stdout)
+	}
+
+	stdout, _, _ = runCmd(t, "--protocol", "1", "cd", "--help")
+	if !strings.Contains(stdout, "Usage:") {
+		t.Errorf("cd --help should print help, got %q", stdout)
+	}
+}
+
+func TestProtocolMismatchWarns(t *testing.T) {
+	for _, version := range []string{"0", "99"} {
+		_, stderr, _ := runCmd(t, "--protocol", version, "cd", "--and-keys", "ESC", "--path", t.TempDir())
+		if !strings.Contains(stderr, "Warning:") || !strings.Contains(stderr, "protocol "+version) {
+			t.Errorf("protocol %s should warn about the shell function, got %q", version, stderr)
+		}
+	}
+}
+
+func TestBashWrapperFollowsDirectives(t *testing.T) {
+	tries := t.TempDir()
+	out := runWrapper(t, tries, `try new-thing --and-keys ENTER && pwd`)
+	if !strings.HasPrefix(strings.TrimSpace(out), filepath.Join(tries, "")) || !strings.Contains(out, "new-thing") {
+		t.Errorf("should cd into the new try, got %q", out)
+	}
+}
+
+func TestBashWrapperPassesHelpThrough(t *testing.T) {
+	out := runWrapper(t, t.TempDir(), `cd / && try --help && pwd`)
+	if !strings.Contains(out, "Usage:") || !strings.HasSuffix(out, "\n/\n") {
+		t.Errorf("help should be printed, not run, got %q", out)
+	}
+}
+
+func TestBashWrapperExecKeepsStdin(t *testing.T) {
+	tries := t.TempDir()
+	os.MkdirAll(filepath.Join(tries, "2025-08-14-old-experiment"), 0755)
+	editor := filepath.Join(t.TempDir(), "editor")
+	os.WriteFile(editor, []byte("#!/bin/sh\nread -r line; echo \"got $line\"\n"), 0755)
+	out := runWrapper(t, tries, `echo typed | EDITOR='`+editor+`' try --and-keys CTRL-O`)
+	if !strings.Contains(out, "got typed") {
+		t.Errorf("exec should read from the shell's stdin, got %q", out)
+	}
+}
+
+func TestBashWrapperEntersTryEnvironment(t *testing.T) {
+	tries := t.TempDir()
+	enter := filepath.Join(tries, "2025-08-14-old-experiment", ".try")
+	os.MkdirAll(enter, 0755)
+	os.WriteFile(filepath.Join(enter, "env"), []byte("# flags\nexport GOFLAGS='-race $x'\nMOCK_API=http://localhost:9\n"), 0644)
+	os.WriteFile(filepath.Join(enter, "on-enter"), []byte("ENTERED=$(basename \"$PWD\")\n"), 0644)
+
+	out := runWrapper(t, tries, `try old --and-keys ENTER && printf '%s|%s|%s\n' "$GOFLAGS" "$MOCK_API" "$ENTERED"`)
+	if !strings.HasSuffix(out, "-race $x|http://localhost:9|2025-08-14-old-experiment\n") {
+		t.Errorf("should export .try/env literally and source .try/on-enter after cd, got %q", out)
+	}
+}
+
+func TestEnterFilesFromClonedReposAreIgnored(t *testing.T) {
+	tries := t.TempDir()
+	path := filepath.Join(tries, "2025-08-14-tobi-try")
+	os.MkdirAll(filepath.Join(path, ".try"), 0755)
+	os.WriteFile(filepath.Join(path, ".try", "on-enter"), []byte("touch pwned\n"), 0644)
+	os.WriteFile(filepath.Join(path, ".try", "env"), []byte("PATH=/tmp\n"), 0644)
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-qm", "x"}} {
+		if out, err := exec.Command("git", append([]string{"-C", path}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	stdout, stderr, _ := runCmd(t, "--protocol", "1", "cd", "tobi", "--and-keys", "ENTER", "--path", tries)
+	if stdout != "#try 1\ncd "+path+"\n" {
+		t.Errorf("tracked .try files should be left out, got %q", stdout)
+	}
+	if !strings.Contains(stderr, "comes with the repository") {
+		t.Errorf("should say why they were ignored, got %q", stderr)
+	}
+}
+
+func TestEnterFilesFromSubmodulesAreIgnored(t *testing.T) {
+	tries := t.TempDir()
+	path := filepath.Join(tries, "2025-08-14-tobi-try")
+	os.MkdirAll(filepath.Join(path, ".try"), 0755)
+	os.WriteFile(filepath.Join(path, ".try", "on-enter"), []byte("touch pwned\n"), 0644)
+	// A checked out submodule is its own work tree, so the superproject
+	// doesn't list the files in it.
+	for _, dir := range []string{path, filepath.Join(path, ".try")} {
+		if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v\n%s", err, out)
+		}
+	}
+
+	stdout, stderr, _ := runCmd(t, "--protocol", "1", "cd", "tobi", "--and-keys", "ENTER", "--path", tries)
+	if stdout != "#try 1\ncd "+path+"\n" {
+		t.Errorf("on-enter from another work tree should be left out, got %q", stdout)
+	}
+	if !strings.Contains(stderr, "belongs to the repository at") {
+		t.Errorf("should say why it was ignored, got %q", stderr)
+	}
+}
+
+func TestScriptsEnterTryEnvironmentOnce(t *testing.T) {
+	tries := t.TempDir()
+	enter := filepath.Join(tries, "2025-08-14-old-experiment", ".try")
+	os.MkdirAll(enter, 0755)
+	os.WriteFile(filepath.Join(enter, "env"), []byte("A=1\n"), 0644)
+	os.WriteFile(filepath.Join(enter, "on-enter"), nil, 0644)
+
+	// --emit-script skips Execute; the legacy output comes after it.
+	for _, flags := range [][]string{{"--emit-script"}, nil} {
+		stdout, _, _ := runCmd(t, append(flags, "cd", "old", "--and-keys", "ENTER", "--path", tries)...)
+		if strings.Count(stdout, "export A='1'") != 1 || strings.Count(stdout, "on-enter") != 1 {
+			t.Errorf("%v: should export .try/env and source on-enter once, got %q", flags, stdout)
+		}
+	}
+}
+
+// Shell functions from before --protocol only eval output containing " && ".
+func TestOutputWithoutProtocolSuitsOldWrappers(t *testing.T) {
+	tries := t.TempDir()
+	path := filepath.Join(tries, "2025-08-14-old-experiment")
+	os.MkdirAll(path, 0755)
+
+	stdout, stderr, _ := runCmd(t, "cd", "old", "--and-keys", "ENTER", "--path", tries)
+	if stdout != "touch '"+path+"' \\\n  && cd '"+path+"'" {
+		t.Errorf("should write a script chained with &&, got %q", stdout)
+	}
+	if strings.Contains(stderr, "reload it") {
+		t.Errorf("scripts and pipes don't need the warning, got %q", stderr)
+	}
+
+	// As the old function ran it: --path first, stdout captured and stderr
+	// on the terminal.
+	if _, err := exec.LookPath("script"); err != nil {
+		t.Skip("script not installed")
+	}
+	bin, _ := filepath.Abs("./try")
+	cmdline := "out=$(" + shellQuoteArgs([]string{bin, "cd", "--path", tries, "old", "--and-keys", "ENTER"}) + "); echo; echo \"[$out]\""
+	out, _ := exec.Command("script", "-qec", "sh -c "+shellQuoteArgs([]string{cmdline}), "/dev/null").CombinedOutput()
+	if !strings.Contains(string(out), "reload it with try init") || !strings.Contains(string(out), "&& cd '"+path+"']") {
+		t.Errorf("should ask the old shell function to be reloaded, got %q", out)
+	}
+}
+
+func TestTasksWithoutTargetFail(t *testing.T) {
+	tasks := []shell.Task{{Type: "cd"}}
+	if _, err := shell.Script(shell.POSIX, tasks); err == nil {
+		t.Error("Script should fail without a target")
+	}
+	if _, err := shell.Execute(shell.POSIX, tasks); err == nil {
+		t.Error("Execute should fail without a target")
+	}
+	if err := shell.WriteDirectives(io.Discard, shell.POSIX, tasks); err == nil {
+		t.Error("WriteDirectives should fail without a target")
+	}
+	if err := shell.RunWithoutWrapper(tasks); err == nil {
+		t.Error("RunWithoutWrapper should fail without a target")
+	}
+}
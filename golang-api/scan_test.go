@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tobi/try/golang-api/internal/index"
+)
+
+func TestScannerFindsAndEnrichesTries(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2025-08-14-gopher"), 0755)
+	os.WriteFile(filepath.Join(dir, "2025-08-14-gopher", "go.mod"), []byte("module x\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "2025-08-15-plain"), 0755)
+	os.WriteFile(filepath.Join(dir, "not-a-dir"), nil, 0644)
+
+	found := make(chan index.Entry, 10)
+	scanner := index.Scanner{Workers: 2, Enrichers: index.DefaultEnrichers, Found: found}
+	idx, err := scanner.Scan(context.Background(), dir, nil)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	close(found)
+
+	if len(idx.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", idx.Entries)
+	}
+	if idx.Entries[0].Name != "2025-08-14-gopher" || idx.Entries[0].Meta["lang"] != "go" {
+		t.Errorf("should detect go in first entry, got %+v", idx.Entries[0])
+	}
+	if lang := idx.Entries[1].Meta["lang"]; lang != "" {
+		t.Errorf("plain dir should have no language, got %q", lang)
+	}
+
+	streamed := 0
+	for range found {
+		streamed++
+	}
+	if streamed != 2 {
+		t.Errorf("expected 2 streamed entries, got %d", streamed)
+	}
+}
+
+func TestScannerReusesMetaForUnchangedEntries(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-cached")
+	os.MkdirAll(path, 0755)
+	stat, _ := os.Stat(path)
+
+	prev := &index.Index{Root: dir, Entries: []index.Entry{
+		{Name: "2025-08-14-cached", Mtime: stat.ModTime(), Meta: map[string]string{"lang": "cobol"}},
+	}}
+
+	idx, err := index.Scan(context.Background(), dir, prev)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if idx.Entries[0].Meta["lang"] != "cobol" {
+		t.Error("should reuse metadata when mtime is unchanged")
+	}
+}
+
+func TestScannerStopsWhenCancelled(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 50; i++ {
+		os.MkdirAll(filepath.Join(dir, fmt.Sprintf("try-%02d", i)), 0755)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := func(ctx context.Context, path string, meta map[string]string) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+	scanner := index.Scanner{Workers: 4, Enrichers: []index.Enricher{slow}}
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	idx, err := scanner.Scan(ctx, dir, nil)
+	if err == nil || idx != nil {
+		t.Error("cancelled scan should return an error and no index")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("scan should stop promptly after cancel, took %s", elapsed)
+	}
+}
+
+func TestScannerRefreshesGitStatusForUnchangedEntries(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "2025-08-14-repo")
+	os.MkdirAll(filepath.Join(path, "src"), 0755)
+	exec.Command("git", "init", "-q", path).Run()
+	os.WriteFile(filepath.Join(path, "src", "main.go"), nil, 0644)
+	stat, _ := os.Stat(path)
+
+	prev := &index.Index{Root: dir, Entries: []index.Entry{
+		{Name: "2025-08-14-repo", Mtime: stat.ModTime(), Meta: map[string]string{"lang": "cobol", "git": "clean"}},
+	}}
+
+	idx, err := index.Scan(context.Background(), dir, prev)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if meta := idx.Entries[0].Meta; meta["lang"] != "cobol" || meta["git"] != "dirty" {
+		t.Errorf("should reuse the language but check git again, got %v", meta)
+	}
+	if prev.Entries[0].Meta["git"] != "clean" {
+		t.Error("should not write to the previous index")
+	}
+}
@@ -37,7 +37,7 @@ func TestTUIRendersWithAndExitAndType(t *testing.T) {
 
 func TestCreateNewGeneratesMkdirScript(t *testing.T) {
 	dir := t.TempDir()
-	stdout, _, _ := runCmd(t, "cd", "new-thing", "--and-keys", "ENTER", "--path", dir)
+	stdout, _, _ := runCmd(t, "cd", "new-thing", "--and-keys", "ENTER", "--emit-script", "--path", dir)
 
 	if !strings.Contains(stdout, "mkdir -p") || !strings.Contains(stdout, "new-thing") {
 		t.Error("should emit mkdir for create new")
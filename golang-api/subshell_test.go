@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runInTerminal runs try with stdout on a pseudo-terminal, as when a person
+// runs it without the shell function.
+func runInTerminal(t *testing.T, env []string, args ...string) string {
+	t.Helper()
+	out, err := terminalCommand(t, env, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("try failed: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+func terminalCommand(t *testing.T, env []string, args ...string) *exec.Cmd {
+	t.Helper()
+	if _, err := exec.LookPath("script"); err != nil {
+		t.Skip("script not installed")
+	}
+	bin, _ := filepath.Abs("./try")
+	// script runs the command line with $SHELL itself, so env is set inside.
+	cmdline := "env " + shellQuoteArgs(append(append(env, bin), args...))
+	cmd := exec.Command("script", "-qec", cmdline, "/dev/null")
+	cmd.Env = append(os.Environ(), "SHELL=/bin/sh")
+	return cmd
+}
+
+func shellQuoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'"'"'`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+func TestWithoutWrapperStartsShellInTry(t *testing.T) {
+	tries := t.TempDir()
+	record := filepath.Join(t.TempDir(), "record")
+	fakeShell := filepath.Join(t.TempDir(), "fake-shell")
+	os.WriteFile(fakeShell, []byte("#!/bin/sh\npwd > '"+record+"'\necho \"$TRY_SUBSHELL\" >> '"+record+"'\n"), 0755)
+
+	out := runInTerminal(t, []string{"SHELL=" + fakeShell}, "--path", tries, "cd", "new-thing", "--and-keys", "ENTER")
+	if !strings.Contains(out, "try init") {
+		t.Errorf("should hint at installing the shell function, got %q", out)
+	}
+	if strings.Contains(out, "cd '") {
+		t.Errorf("should not print a script nobody runs, got %q", out)
+	}
+
+	data, _ := os.ReadFile(record)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || filepath.Dir(lines[0]) != tries || !strings.HasSuffix(lines[0], "new-thing") || lines[1] != lines[0] {
+		t.Errorf("the shell should start in the new try with TRY_SUBSHELL set, got %q", data)
+	}
+}
+
+func TestWithoutWrapperTakesQueries(t *testing.T) {
+	tries := t.TempDir()
+	path := filepath.Join(tries, "2025-08-14-redis-pool")
+	os.MkdirAll(path, 0755)
+	record := filepath.Join(t.TempDir(), "record")
+	fakeShell := filepath.Join(t.TempDir(), "fake-shell")
+	os.WriteFile(fakeShell, []byte("#!/bin/sh\npwd >> '"+record+"'\n"), 0755)
+	env := []string{"SHELL=" + fakeShell, "TRY_PATH=" + tries}
+
+	runInTerminal(t, env, "redis", "--and-keys", "ENTER")
+	runInTerminal(t, env, "--and-keys", "ENTER")
+	if data, _ := os.ReadFile(record); string(data) != path+"\n"+path+"\n" {
+		t.Errorf("a query, or none, should open the selector like try cd, got %q", data)
+	}
+}
+
+func TestWithoutWrapperPrintsPath(t *testing.T) {
+	tries := t.TempDir()
+	path := filepath.Join(tries, "2025-08-14-old-experiment")
+	os.MkdirAll(path, 0755)
+
+	out := runInTerminal(t, []string{"SHELL=/bin/false"}, "--path", tries, "cd", "--and-keys", "CTRL-Y")
+	if !strings.Contains(out, path) || strings.Contains(out, "printf") || strings.Contains(out, "Starting") {
+		t.Errorf("Ctrl-Y should just print the path, got %q", out)
+	}
+}
+
+func TestWithoutWrapperSubshellGetsTryEnv(t *testing.T) {
+	tries := t.TempDir()
+	enter := filepath.Join(tries, "2025-08-14-old-experiment", ".try")
+	os.MkdirAll(enter, 0755)
+	os.WriteFile(filepath.Join(enter, "env"), []byte("PYTHONPATH=src\n"), 0644)
+	os.WriteFile(filepath.Join(enter, "on-enter"), []byte("echo hi\n"), 0644)
+	record := filepath.Join(t.TempDir(), "record")
+	fakeShell := filepath.Join(t.TempDir(), "fake-shell")
+	os.WriteFile(fakeShell, []byte("#!/bin/sh\necho \"$PYTHONPATH\" > '"+record+"'\n"), 0755)
+
+	out := runInTerminal(t, []string{"SHELL=" + fakeShell}, "--path", tries, "cd", "old", "--and-keys", "ENTER")
+	if data, _ := os.ReadFile(record); string(data) != "src\n" {
+		t.Errorf("the subshell should get .try/env, got %q", data)
+	}
+	if !strings.Contains(out, "Skipping") {
+		t.Errorf("should say the on-enter script was skipped, got %q", out)
+	}
+}
+
+func TestWithoutWrapperRefusesToNestSubshells(t *testing.T) {
+	tries := t.TempDir()
+	out, err := terminalCommand(t, []string{"SHELL=/bin/false", "TRY_SUBSHELL=/somewhere"}, "--path", tries, "cd", "new-thing", "--and-keys", "ENTER").CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "already in a try subshell") || strings.Contains(string(out), "Starting") {
+		t.Errorf("should ask to exit the subshell instead of nesting, got %q", out)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(tries, "*new-thing")); len(matches) != 0 {
+		t.Errorf("should not create the try, got %v", matches)
+	}
+}
+
+func TestWrappedRunsNeverStartSubshells(t *testing.T) {
+	tries := t.TempDir()
+	out := runInTerminal(t, []string{"SHELL=/bin/false", "TRY_WRAPPED=1"}, "--path", tries, "cd", "new-thing", "--and-keys", "ENTER")
+	if strings.Contains(out, "Starting") || !strings.Contains(out, "cd '") {
+		t.Errorf("TRY_WRAPPED should get a script, not a subshell, got %q", out)
+	}
+}
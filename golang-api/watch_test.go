@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tobi/try/golang-api/internal/watch"
+)
+
+func TestWatcherSignalsNewAndRemovedTries(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("filesystem watching is linux-only")
+	}
+
+	dir := t.TempDir()
+	w, err := watch.New(dir)
+	if err != nil {
+		t.Fatalf("watch.New: %v", err)
+	}
+	defer w.Close()
+
+	expectEvent := func(what string) {
+		t.Helper()
+		select {
+		case <-w.Events:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event after %s", what)
+		}
+	}
+
+	path := filepath.Join(dir, "2025-08-14-live")
+	os.Mkdir(path, 0755)
+	expectEvent("mkdir")
+
+	os.Remove(path)
+	expectEvent("remove")
+}
+
+func TestWatcherCloseStopsEvents(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("filesystem watching is linux-only")
+	}
+
+	dir := t.TempDir()
+	w, err := watch.New(dir)
+	if err != nil {
+		t.Fatalf("watch.New: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+
+	os.Mkdir(filepath.Join(dir, "after-close"), 0755)
+	select {
+	case <-w.Events:
+		t.Error("should not signal after Close")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -14,7 +14,7 @@ func TestWorktreeDirWithName(t *testing.T) {
 	repo := t.TempDir()
 	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
 
-	stdout, _, err := runCmdInDir(t, repo, "worktree", "dir", "xyz", "--path", tries)
+	stdout, _, err := runCmdInDir(t, repo, "worktree", "dir", "xyz", "--emit-script", "--path", tries)
 	if err != nil {
 		t.Logf("command failed (may be expected): %v", err)
 	}
@@ -40,7 +40,7 @@ func TestWorktreeDirWithoutGitSkipsWorktreeAndEcho(t *testing.T) {
 	repo := t.TempDir()
 	// No .git directory
 
-	stdout, _, _ := runCmdInDir(t, repo, "worktree", "dir", "xyz", "--path", tries)
+	stdout, _, _ := runCmdInDir(t, repo, "worktree", "dir", "xyz", "--emit-script", "--path", tries)
 
 	out := stdout
 	if strings.Contains(out, "worktree add --detach") {
@@ -60,7 +60,7 @@ func TestTryDotEmitsWorktreeStepAndUsesCwdName(t *testing.T) {
 	os.MkdirAll(filepath.Join(proj, ".git"), 0755)
 	tries := t.TempDir()
 
-	stdout, _, _ := runCmdInDir(t, proj, "cd", "./", "--path", tries)
+	stdout, _, _ := runCmdInDir(t, proj, "cd", "./", "--emit-script", "--path", tries)
 
 	out := stdout
 	if !strings.Contains(out, "worktree add --detach") {
@@ -80,7 +80,7 @@ func TestTryDotWithNameOverridesBasename(t *testing.T) {
 	os.MkdirAll(filepath.Join(proj, ".git"), 0755)
 	tries := t.TempDir()
 
-	stdout, _, _ := runCmdInDir(t, proj, "cd", ".", "custom-name", "--path", tries)
+	stdout, _, _ := runCmdInDir(t, proj, "cd", ".", "custom-name", "--emit-script", "--path", tries)
 
 	out := stdout
 	if !strings.Contains(out, "worktree add --detach") || !strings.Contains(out, "custom-name") {
@@ -97,7 +97,7 @@ func TestTryDotWithoutGitSkipsWorktree(t *testing.T) {
 	os.MkdirAll(proj, 0755)
 	tries := t.TempDir()
 
-	stdout, _, _ := runCmdInDir(t, proj, "cd", ".", "--path", tries)
+	stdout, _, _ := runCmdInDir(t, proj, "cd", ".", "--emit-script", "--path", tries)
 
 	out := stdout
 	if strings.Contains(out, "worktree add --detach") {
@@ -146,3 +146,201 @@ func runCmdInDir(t *testing.T, dir string, args ...string) (string, string, erro
 
 	return string(stdoutBuf), string(stderrBuf), err
 }
+
+func TestWorktreeFlagsNeedValues(t *testing.T) {
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+
+	for _, args := range [][]string{
+		{"cd", ".", "--branch"},
+		{"worktree", "dir", "--checkout", "--ref", "main"},
+		{"worktree", "dir", "--ref="},
+		{"cd", ".", "--pr"},
+	} {
+		_, stderr, err := runCmdInDir(t, repo, append(args, "--dry-run", "--path", t.TempDir())...)
+		if err == nil || !strings.Contains(stderr, "needs a value") {
+			t.Errorf("%v should fail for the missing value, got %v %q", args, err, stderr)
+		}
+	}
+}
+
+func TestWorktreeBranchCreatesPrefixedBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := newTestRepo(t, map[string]string{"README.md": "hi\n"})
+	tries := t.TempDir()
+
+	script, _, err := runCmdInDir(t, repo, "worktree", "dir", "retry", "--branch", "retry-logic", "--path", tries)
+	if err != nil {
+		t.Fatalf("worktree should succeed: %v", err)
+	}
+	// worktree dir works on the repository around the cwd.
+	run := exec.Command("sh", "-c", script)
+	run.Dir = repo
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(tries, "*retry"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one worktree, got %v", matches)
+	}
+	if branch := gitIn(t, matches[0], "rev-parse", "--abbrev-ref", "HEAD"); branch != "try/retry-logic" {
+		t.Errorf("worktree should be on try/retry-logic, got %q", branch)
+	}
+}
+
+func TestWorktreeBranchPrefixIsConfigurable(t *testing.T) {
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	cfg := writeConfig(t, `{"worktree_branch_prefix": "me/"}`)
+
+	stdout, _, _ := runCmdWithEnv(t, map[string]string{"TRY_CONFIG": cfg}, "worktree", repo, "--branch", "fix", "--emit-script", "--path", t.TempDir())
+	if !strings.Contains(stdout, "worktree add -b") || !strings.Contains(stdout, "me/fix") || strings.Contains(stdout, "try/fix") {
+		t.Errorf("should use the configured branch prefix, got %q", stdout)
+	}
+}
+
+func TestWorktreeCheckoutAndRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := newTestRepo(t, map[string]string{"README.md": "v1\n"})
+	gitIn(t, repo, "tag", "v1")
+	gitIn(t, repo, "branch", "feature")
+	os.WriteFile(filepath.Join(repo, "README.md"), []byte("v2\n"), 0644)
+	gitIn(t, repo, "commit", "-q", "-am", "v2")
+	tries := t.TempDir()
+
+	script, _, _ := runCmdInDir(t, repo, "cd", ".", "feat", "--checkout", "feature", "--path", tries)
+	if out, err := exec.Command("sh", "-c", script).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	matches, _ := filepath.Glob(filepath.Join(tries, "*feat"))
+	if len(matches) != 1 || gitIn(t, matches[0], "rev-parse", "--abbrev-ref", "HEAD") != "feature" {
+		t.Errorf("--checkout should check out the existing branch, got %v", matches)
+	}
+
+	script, _, _ = runCmdInDir(t, repo, "cd", ".", "old", "--ref", "v1", "--path", tries)
+	if out, err := exec.Command("sh", "-c", script).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	matches, _ = filepath.Glob(filepath.Join(tries, "*old"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one worktree, got %v", matches)
+	}
+	if content, _ := os.ReadFile(filepath.Join(matches[0], "README.md")); string(content) != "v1\n" {
+		t.Errorf("--ref should start the worktree at the tag, got %q", content)
+	}
+}
+
+func TestWorktreeBranchAndCheckoutConflict(t *testing.T) {
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+
+	_, stderr, err := runCmdInDir(t, repo, "worktree", "dir", "--branch", "a", "--checkout", "b", "--path", t.TempDir())
+	if err == nil || !strings.Contains(stderr, "can't be combined") {
+		t.Errorf("--branch with --checkout should fail, got %v %q", err, stderr)
+	}
+}
+
+func TestWorktreePullRequestFromBareRemote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	upstream := newTestRepo(t, map[string]string{"README.md": "main\n"})
+	bare := filepath.Join(t.TempDir(), "remote.git")
+	gitIn(t, upstream, "clone", "-q", "--bare", upstream, bare)
+	gitIn(t, upstream, "checkout", "-q", "-b", "contributor")
+	os.WriteFile(filepath.Join(upstream, "README.md"), []byte("from pr\n"), 0644)
+	gitIn(t, upstream, "commit", "-q", "-am", "pr change")
+	gitIn(t, upstream, "push", "-q", bare, "contributor:refs/pull/42/head")
+
+	repo := filepath.Join(t.TempDir(), "myrepo")
+	gitIn(t, t.TempDir(), "clone", "-q", bare, repo)
+	tries := t.TempDir()
+
+	script, stderr, err := runCmd(t, "worktree", repo, "--pr", "42", "--path", tries)
+	if err != nil {
+		t.Fatalf("worktree --pr should succeed: %v\n%s", err, stderr)
+	}
+	if out, err := exec.Command("sh", "-c", script).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(tries, "*-myrepo-pr-42"))
+	if len(matches) != 1 {
+		t.Fatalf("expected a try named after the pull request, got %v", matches)
+	}
+	if content, _ := os.ReadFile(filepath.Join(matches[0], "README.md")); string(content) != "from pr\n" {
+		t.Errorf("worktree should be at the pull request head, got %q", content)
+	}
+}
+
+func TestWorktreePullRequestFindsMergeRequestsOnAnyHost(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	// A self-hosted GitLab: merge request refs, and no "gitlab" in the URL.
+	upstream := newTestRepo(t, map[string]string{"README.md": "main\n"})
+	bare := filepath.Join(t.TempDir(), "remote.git")
+	gitIn(t, upstream, "clone", "-q", "--bare", upstream, bare)
+	gitIn(t, upstream, "push", "-q", bare, "HEAD:refs/merge-requests/7/head")
+	repo := filepath.Join(t.TempDir(), "myrepo")
+	gitIn(t, t.TempDir(), "clone", "-q", bare, repo)
+
+	stdout, stderr, _ := runCmd(t, "worktree", repo, "--pr", "7", "--emit-script", "--path", t.TempDir())
+	if !strings.Contains(stdout, "refs/merge-requests/7/head") {
+		t.Errorf("should fetch the merge request ref the remote has, got %q %q", stdout, stderr)
+	}
+
+	_, stderr, err := runCmd(t, "worktree", repo, "--pr", "8", "--emit-script", "--path", t.TempDir())
+	if err == nil || !strings.Contains(stderr, "no pull or merge request 8") {
+		t.Errorf("a missing request should fail, got %v %q", err, stderr)
+	}
+}
+
+func TestWorktreePullRequestNeedsRemote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := newTestRepo(t, map[string]string{"README.md": "hi\n"})
+	_, stderr, err := runCmd(t, "worktree", repo, "--pr", "7", "--path", t.TempDir())
+	if err == nil || !strings.Contains(stderr, "no remote") {
+		t.Errorf("--pr without a remote should fail, got %v %q", err, stderr)
+	}
+}
+
+func TestWorktreeFailureReportsAndRollsBack(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := newTestRepo(t, map[string]string{"README.md": "hi\n"})
+	tries := t.TempDir()
+
+	script, _, _ := runCmd(t, "worktree", repo, "broken", "--checkout", "no-such-branch", "--emit-script", "--path", tries)
+	if strings.Contains(script, "|| true") {
+		t.Error("git failures should no longer be swallowed")
+	}
+	run := exec.Command("sh", "-c", script+" && echo entered")
+	out, err := run.CombinedOutput()
+	if err == nil {
+		t.Fatal("script should fail when git worktree add fails")
+	}
+	if !strings.Contains(string(out), "no-such-branch") {
+		t.Errorf("git's error should be shown, got %q", out)
+	}
+	if strings.Contains(string(out), "entered") {
+		t.Error("should not continue to cd after a failure")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(tries, "*broken")); len(matches) != 0 {
+		t.Errorf("the new try should be removed again, got %v", matches)
+	}
+}